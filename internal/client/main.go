@@ -63,6 +63,13 @@ type State struct {
 	Map    Map
 	Masks  []Mask
 	Cells  []Cell
+	Paused bool
+	Replay *Replay
+}
+
+type Replay struct {
+	Time     float64
+	Duration float64
 }
 
 func NewState(w, h string) (*State, error) {
@@ -90,6 +97,14 @@ func (v *State) Update(e *engine.Engine) {
 	defer v.mu.Unlock()
 	screen := v.Screen
 	screen.ScaleTo(e.World.Width, e.World.Height)
+	v.Paused = e.Paused()
+	if e.Replaying() {
+		tickRate := float64(e.TickRate())
+		v.Replay = &Replay{
+			Time:     float64(e.Tick()) / tickRate,
+			Duration: float64(e.ReplayTicks()) / tickRate,
+		}
+	}
 
 	if screen.xOffset > 0 {
 		v.Masks = []Mask{
@@ -124,9 +139,9 @@ func (v *State) Update(e *engine.Engine) {
 	}
 
 	for _, entity := range e.World.Entities {
-		t := e.World.Components.EntityTypes[entity]
-		position, hasPosition := e.World.Components.Positions[entity]
-		bb, hasBb := e.World.Components.BoundingBoxes[entity]
+		t, _ := e.World.Components.EntityTypes.Get(entity)
+		position, hasPosition := e.World.Components.Positions.Get(entity)
+		bb, hasBb := e.World.Components.BoundingBoxes.Get(entity)
 		if !hasPosition || !hasBb {
 			continue
 		}
@@ -135,7 +150,7 @@ func (v *State) Update(e *engine.Engine) {
 			continue
 		}
 
-		health, hasHealth := e.World.Components.Healths[entity]
+		health, hasHealth := e.World.Components.Healths.Get(entity)
 		isDead := false
 		if hasHealth && health.Dead {
 			isDead = true
@@ -151,11 +166,14 @@ func (v *State) Update(e *engine.Engine) {
 			zIndex--
 		}
 
+		// Positions are centers, cells are placed by their top left corner.
+		width := v.Screen.MapLength(bb.W, 10)
+		height := v.Screen.MapLength(bb.H, 10)
 		v.Cells = append(v.Cells, Cell{
-			Width:    v.Screen.MapLength(bb.W, 10),
-			Height:   v.Screen.MapLength(bb.H, 10),
-			Left:     v.Screen.MapX(position.X),
-			Top:      v.Screen.MapY(position.Y),
+			Width:    width,
+			Height:   height,
+			Left:     v.Screen.MapX(position.X) - width/2,
+			Top:      v.Screen.MapY(position.Y) - height/2,
 			Rotation: position.Direction + physics.Deg90,
 			Image:    image,
 			ZIndex:   zIndex,
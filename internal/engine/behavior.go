@@ -0,0 +1,42 @@
+package engine
+
+import (
+	"fmt"
+
+	"cfichtmueller.com/htmx-game/internal/engine/bhv"
+)
+
+const (
+	BehaviorTankShelter = "tankShelter"
+	BehaviorTank        = "tank"
+	BehaviorTower       = "tower"
+)
+
+// BehaviorFactory builds the behavior tree of an entity.
+type BehaviorFactory func(world *World, entity Entity) *bhv.Tree
+
+var (
+	behaviorFactories    = make(map[string]BehaviorFactory)
+	behaviorRequirements = make(map[string][]string)
+)
+
+func init() {
+	RegisterBehavior(BehaviorTankShelter, tankShelterBehavior, "spawners")
+	RegisterBehavior(BehaviorTank, tankBehavior, "autoMove", "sensings")
+	RegisterBehavior(BehaviorTower, towerBehavior, "autoMove")
+}
+
+// RegisterBehavior makes a behavior available by name. Prefabs using it need the required component stores.
+// Trees can't be serialized, so restoring a snapshot rebuilds them with their factory.
+func RegisterBehavior(name string, factory BehaviorFactory, requires ...string) {
+	behaviorFactories[name] = factory
+	behaviorRequirements[name] = requires
+}
+
+func NewBehavior(world *World, entity Entity, name string) (*Behavior, error) {
+	factory, ok := behaviorFactories[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown behavior %s", name)
+	}
+	return &Behavior{Name: name, Tree: factory(world, entity)}, nil
+}
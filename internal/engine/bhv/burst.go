@@ -1,11 +1,15 @@
 package bhv
 
-import "math"
+import (
+	"encoding/json"
+	"math"
+)
 
 type BurstState struct {
 	BurstSize   int
 	BurstSizeFn func() int
 	Interval    float64
+	IntervalFn  func() float64
 	remaining   int
 	timeToNext  float64
 }
@@ -21,7 +25,7 @@ func BurstBehavior(s *BurstState, child *Node) *Node {
 				if d.BurstSizeFn != nil {
 					d.remaining = d.BurstSizeFn()
 				}
-				d.timeToNext = d.Interval
+				d.timeToNext = d.interval()
 				return StatusSuccess
 			}
 			d.timeToNext = math.Max(0, d.timeToNext-dt)
@@ -29,7 +33,7 @@ func BurstBehavior(s *BurstState, child *Node) *Node {
 				return StatusRunning
 			}
 			d.remaining -= 1
-			d.timeToNext = d.Interval
+			d.timeToNext = d.interval()
 			s := n.Children[0].Tick(dt)
 			if s != StatusSuccess {
 				return s
@@ -38,3 +42,29 @@ func BurstBehavior(s *BurstState, child *Node) *Node {
 		},
 	}
 }
+
+func (s *BurstState) interval() float64 {
+	if s.IntervalFn != nil {
+		return s.IntervalFn()
+	}
+	return s.Interval
+}
+
+type burstSnapshot struct {
+	Remaining  int     `json:"remaining"`
+	TimeToNext float64 `json:"timeToNext"`
+}
+
+func (s *BurstState) SaveState() (json.RawMessage, error) {
+	return json.Marshal(burstSnapshot{Remaining: s.remaining, TimeToNext: s.timeToNext})
+}
+
+func (s *BurstState) LoadState(data json.RawMessage) error {
+	var snapshot burstSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return err
+	}
+	s.remaining = snapshot.Remaining
+	s.timeToNext = snapshot.TimeToNext
+	return nil
+}
@@ -0,0 +1,70 @@
+package bhv
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// StateSaver is implemented by node data that keeps state between ticks.
+type StateSaver interface {
+	SaveState() (json.RawMessage, error)
+	LoadState(data json.RawMessage) error
+}
+
+// Walk calls f for n and all of its descendants in depth-first order.
+func (n *Node) Walk(f func(n *Node)) {
+	f(n)
+	for _, c := range n.Children {
+		c.Walk(f)
+	}
+}
+
+// SaveState returns the state of all stateful nodes in depth-first order.
+func (t *Tree) SaveState() ([]json.RawMessage, error) {
+	states := make([]json.RawMessage, 0)
+	if t.Root == nil {
+		return states, nil
+	}
+	var err error
+	t.Root.Walk(func(n *Node) {
+		saver, ok := n.Data.(StateSaver)
+		if !ok || err != nil {
+			return
+		}
+		var state json.RawMessage
+		state, err = saver.SaveState()
+		states = append(states, state)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to save node state: %v", err)
+	}
+	return states, nil
+}
+
+// LoadState restores states created by SaveState into a tree of the same shape.
+func (t *Tree) LoadState(states []json.RawMessage) error {
+	if t.Root == nil {
+		return nil
+	}
+	i := 0
+	var err error
+	t.Root.Walk(func(n *Node) {
+		saver, ok := n.Data.(StateSaver)
+		if !ok || err != nil {
+			return
+		}
+		if i >= len(states) {
+			err = fmt.Errorf("tree has more stateful nodes than saved states (%d)", len(states))
+			return
+		}
+		err = saver.LoadState(states[i])
+		i++
+	})
+	if err != nil {
+		return fmt.Errorf("unable to load node state: %v", err)
+	}
+	if i != len(states) {
+		return fmt.Errorf("tree has %d stateful nodes, got %d saved states", i, len(states))
+	}
+	return nil
+}
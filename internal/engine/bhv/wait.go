@@ -1,6 +1,9 @@
 package bhv
 
-import "math"
+import (
+	"encoding/json"
+	"math"
+)
 
 type WaitState struct {
 	InitialWait   float64
@@ -39,3 +42,20 @@ func WaitNode(s *WaitState, child *Node) *Node {
 		},
 	}
 }
+
+type waitSnapshot struct {
+	TimeRemaining float64 `json:"timeRemaining"`
+}
+
+func (s *WaitState) SaveState() (json.RawMessage, error) {
+	return json.Marshal(waitSnapshot{TimeRemaining: s.timeRemaining})
+}
+
+func (s *WaitState) LoadState(data json.RawMessage) error {
+	var snapshot waitSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return err
+	}
+	s.timeRemaining = snapshot.TimeRemaining
+	return nil
+}
@@ -1,12 +1,12 @@
 package engine
 
-import "cfichtmueller.com/htmx-game/internal/engine/physics"
+const PrefabBullet = "bullet"
 
-func SpawnBullet(world *World, x, y, direction, velocity, ttl float64) {
-	entity := world.AddEntity(Bullet)
+// SpawnBullet spawns a bullet at x, y. The bullet is on the team of the shooter.
+func SpawnBullet(world *World, shooter Entity, x, y, direction float64) Entity {
+	entity := world.mustSpawn(PrefabBullet, x, y, direction)
+	world.inheritTeam(entity, shooter)
 
-	world.Components.Positions[entity] = &physics.Position{X: x - 5, Y: y - 5, Direction: direction}
-	world.Components.Velocities[entity] = &Velocity{Current: velocity}
-	world.Components.BoundingBoxes[entity] = &physics.Rectangle{W: 10, H: 10}
-	world.Components.Healths[entity] = &Health{Ages: true, TTL: ttl}
+	Publish(world.Events, BulletFired{Bullet: entity, Shooter: shooter})
+	return entity
 }
@@ -1,49 +1,102 @@
 package engine
 
 import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
 	"cfichtmueller.com/htmx-game/internal/engine/bhv"
 	"cfichtmueller.com/htmx-game/internal/engine/physics"
 )
 
 type ComponentStorage struct {
-	Accelerations map[Entity]*Acceleration
-	AutoMove      map[Entity]*AutoMove
-	Behaviors     map[Entity]*Behavior
-	BoundingBoxes map[Entity]*physics.Rectangle
-	Frictions     map[Entity]*Friction
-	Healths       map[Entity]*Health
-	Positions     map[Entity]*physics.Position
-	Sensings      map[Entity]*Sensing
-	Velocities    map[Entity]*Velocity
-	EntityTypes   map[Entity]*EntityTypeComponent
+	Accelerations  *Store[Acceleration]
+	AutoMove       *Store[AutoMove]
+	Behaviors      *Store[Behavior]
+	BoundingBoxes  *Store[physics.Rectangle]
+	Colliders      *Store[Collider]
+	Frictions      *Store[Friction]
+	Healths        *Store[Health]
+	Parents        *Store[Parent]
+	RigidBodies    *Store[RigidBody]
+	Positions      *Store[physics.Position]
+	Sensings       *Store[Sensing]
+	Spawners       *Store[Spawner]
+	Sweeps         *Store[Sweep]
+	Teams          *Store[Team]
+	Velocities     *Store[Velocity]
+	EntityTypes    *Store[EntityTypeComponent]
+	stores         []store
+	byName         map[string]store
+	byType         map[reflect.Type]store
+	queries        map[string]*Query
+	queriesByStore map[store][]*Query
 }
 
 func NewComponentStorage() *ComponentStorage {
-	return &ComponentStorage{
-		Accelerations: make(map[Entity]*Acceleration),
-		AutoMove:      make(map[Entity]*AutoMove),
-		Behaviors:     make(map[Entity]*Behavior),
-		BoundingBoxes: make(map[Entity]*physics.Rectangle),
-		Frictions:     make(map[Entity]*Friction),
-		Healths:       make(map[Entity]*Health),
-		Positions:     make(map[Entity]*physics.Position),
-		Sensings:      make(map[Entity]*Sensing),
-		Velocities:    make(map[Entity]*Velocity),
-		EntityTypes:   make(map[Entity]*EntityTypeComponent),
+	s := &ComponentStorage{
+		stores:         make([]store, 0),
+		byName:         make(map[string]store),
+		byType:         make(map[reflect.Type]store),
+		queries:        make(map[string]*Query),
+		queriesByStore: make(map[store][]*Query),
 	}
+	s.Accelerations = RegisterStore[Acceleration](s, "accelerations")
+	s.AutoMove = RegisterStore[AutoMove](s, "autoMove")
+	s.Behaviors = RegisterStore[Behavior](s, "behaviors")
+	s.BoundingBoxes = RegisterStore[physics.Rectangle](s, "boundingBoxes")
+	s.Colliders = RegisterStore[Collider](s, "colliders")
+	s.Frictions = RegisterStore[Friction](s, "frictions")
+	s.Healths = RegisterStore[Health](s, "healths")
+	s.Parents = RegisterStore[Parent](s, "parents")
+	s.RigidBodies = RegisterStore[RigidBody](s, "rigidBodies")
+	s.Positions = RegisterStore[physics.Position](s, "positions")
+	s.Sensings = RegisterStore[Sensing](s, "sensings")
+	s.Spawners = RegisterStore[Spawner](s, "spawners")
+	s.Sweeps = RegisterStore[Sweep](s, "sweeps")
+	s.Teams = RegisterStore[Team](s, "teams")
+	s.Velocities = RegisterStore[Velocity](s, "velocities")
+	s.EntityTypes = RegisterStore[EntityTypeComponent](s, "entityTypes")
+	return s
 }
 
 func (s *ComponentStorage) RemoveEntity(entity Entity) {
-	delete(s.Accelerations, entity)
-	delete(s.AutoMove, entity)
-	delete(s.Behaviors, entity)
-	delete(s.BoundingBoxes, entity)
-	delete(s.Frictions, entity)
-	delete(s.Healths, entity)
-	delete(s.Positions, entity)
-	delete(s.Sensings, entity)
-	delete(s.Velocities, entity)
-	delete(s.EntityTypes, entity)
+	for _, store := range s.stores {
+		store.Remove(entity)
+	}
+}
+
+func (s *ComponentStorage) MarshalJSON() ([]byte, error) {
+	stores := make(map[string]json.RawMessage, len(s.stores))
+	for _, store := range s.stores {
+		b, err := store.MarshalJSON()
+		if err != nil {
+			return nil, fmt.Errorf("unable to marshal %s: %v", store.Name(), err)
+		}
+		stores[store.Name()] = b
+	}
+	return json.Marshal(stores)
+}
+
+// UnmarshalJSON replaces the components of all registered stores. Stores have to be registered before.
+func (s *ComponentStorage) UnmarshalJSON(data []byte) error {
+	var stores map[string]json.RawMessage
+	if err := json.Unmarshal(data, &stores); err != nil {
+		return err
+	}
+	for _, store := range s.stores {
+		store.clear()
+	}
+	for name, b := range stores {
+		store, ok := s.byName[name]
+		if !ok {
+			return fmt.Errorf("unknown component store %s", name)
+		}
+		if err := store.UnmarshalJSON(b); err != nil {
+			return fmt.Errorf("unable to unmarshal %s: %v", name, err)
+		}
+	}
+	return nil
 }
 
 type Acceleration struct {
@@ -64,7 +117,25 @@ func (a *AutoMove) SetTargetDirection(d float64) {
 }
 
 type Behavior struct {
-	Tree *bhv.Tree
+	Name string
+	Tree *bhv.Tree `json:"-"`
+}
+
+// Collider replaces the bounding box as collision shape. Exactly one shape is set.
+type Collider struct {
+	Circle  *physics.Circle  `json:",omitempty"`
+	Polygon *physics.Polygon `json:",omitempty"`
+}
+
+// Shape returns the shape that is set, or nil.
+func (c *Collider) Shape() physics.Shape {
+	switch {
+	case c.Circle != nil:
+		return *c.Circle
+	case c.Polygon != nil:
+		return *c.Polygon
+	}
+	return nil
 }
 
 type EntityTypeComponent struct {
@@ -86,15 +157,59 @@ type Health struct {
 	Decayed  bool
 }
 
+// RemovalPolicy decides what happens to children when their parent is removed.
+type RemovalPolicy string
+
+const (
+	// RemoveChildren removes the children together with the parent.
+	RemoveChildren RemovalPolicy = "remove"
+	// DetachChildren keeps the children where they are.
+	DetachChildren RemovalPolicy = "detach"
+)
+
+// Parent attaches an entity to another one. The transform system places the entity at Offset from
+// the center of the parent, rotated by the parent's direction. Rotation is added to the parent's direction.
+type Parent struct {
+	Entity   Entity
+	OffsetX  float64
+	OffsetY  float64
+	Rotation float64
+	Policy   RemovalPolicy
+}
+
+// RigidBody makes an entity solid. Overlapping bodies are pushed apart, dynamic bodies move
+// inversely to their mass and static bodies don't move. Restitution between 0 and 1 decides how
+// much a dynamic body bounces off, 0 keeps its direction. Dead dynamic bodies aren't solid.
+// Bodies without a positive mass are static.
+type RigidBody struct {
+	Static      bool
+	Mass        float64
+	Restitution float64
+}
+
+func (b *RigidBody) static() bool {
+	return b.Static || b.Mass <= 0
+}
+
+func (b *RigidBody) inverseMass() float64 {
+	if b.static() {
+		return 0
+	}
+	return 1 / b.Mass
+}
+
 type SensedEntity struct {
 	Entity   Entity
 	Type     EntityType
 	Position *physics.Position
 }
 
+// Sensing senses entities of the given types in range. With HostileOnly, entities that are
+// on the same team are ignored.
 type Sensing struct {
-	SensedEntities []SensedEntity
+	SensedEntities []SensedEntity `json:"-"`
 	Ranges         map[EntityType]float64
+	HostileOnly    bool
 }
 
 func NewSensing() *Sensing {
@@ -108,9 +223,39 @@ func (s *Sensing) SetRange(t EntityType, d float64) *Sensing {
 	return s
 }
 
+// Spawner periodically spawns entities from a prefab.
+type Spawner struct {
+	Prefab      string
+	IntervalMin float64
+	IntervalMax float64
+}
+
+// Sweep makes collision detection test the whole path an entity moved along during the tick
+// instead of where it ended up, so fast entities can't skip over others. Only entities with a
+// circle collider are swept.
+type Sweep struct {
+	// From is where the entity was before it moved. It is set by the movement system.
+	From physics.Vec2
+}
+
+// Team groups entities that are friendly to each other. Entities without a team are hostile to everyone.
+type Team struct {
+	Name string
+}
+
+// Velocity moves an entity by Linear each second. Entities drive like tanks, turning turns Linear
+// with them. Acceleration and Max work on the speed along the direction the entity faces, friction
+// slows it down in any direction. In prefabs, Linear is given relative to the entity, X is forward.
 type Velocity struct {
-	Current        float64
+	Linear         physics.Vec2
 	Max            float64
 	AngularCurrent float64
 	AngularMax     float64
 }
+
+// Turn turns the velocity by angle a, like the entity.
+func (v *Velocity) Turn(a float64) {
+	if a != 0 {
+		v.Linear = v.Linear.Rotate(a)
+	}
+}
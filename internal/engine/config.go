@@ -0,0 +1,161 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"cfichtmueller.com/htmx-game/internal/engine/physics"
+)
+
+// Config holds the gameplay tunables. Changes apply to entities spawned afterwards, tower timings
+// apply to all towers right away.
+// Prefab files override the config, it only fills in fields a prefab leaves out.
+type Config struct {
+	Player       PlayerConfig       `json:"player"`
+	Bullet       BulletConfig       `json:"bullet"`
+	Tank         TankConfig         `json:"tank"`
+	Tower        TowerConfig        `json:"tower"`
+	SpeedPowerUp SpeedPowerUpConfig `json:"speedPowerUp"`
+}
+
+type PlayerConfig struct {
+	MaxVelocity float64 `json:"maxVelocity"`
+}
+
+type BulletConfig struct {
+	Velocity float64 `json:"velocity"`
+	TTL      float64 `json:"ttl"`
+}
+
+type TankConfig struct {
+	TTL float64 `json:"ttl"`
+}
+
+type TowerConfig struct {
+	AimInterval   Range    `json:"aimInterval"`
+	BurstInterval float64  `json:"burstInterval"`
+	BurstSize     IntRange `json:"burstSize"`
+	Spread        float64  `json:"spread"`
+}
+
+type SpeedPowerUpConfig struct {
+	Bonus         float64 `json:"bonus"`
+	TTL           float64 `json:"ttl"`
+	SpawnInterval Range   `json:"spawnInterval"`
+}
+
+type Range struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+type IntRange struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Player: PlayerConfig{MaxVelocity: 50},
+		Bullet: BulletConfig{Velocity: 70, TTL: 10},
+		Tank:   TankConfig{TTL: 30},
+		Tower: TowerConfig{
+			AimInterval:   Range{Min: 5, Max: 10},
+			BurstInterval: 0.3,
+			BurstSize:     IntRange{Min: 3, Max: 6},
+			Spread:        0.02,
+		},
+		SpeedPowerUp: SpeedPowerUpConfig{
+			Bonus:         5,
+			TTL:           30,
+			SpawnInterval: Range{Min: 5, Max: 7},
+		},
+	}
+}
+
+func (c Config) Validate() error {
+	var errs []string
+	positive := func(name string, v float64) {
+		if v <= 0 {
+			errs = append(errs, name+" must be greater than 0")
+		}
+	}
+	notNegative := func(name string, v float64) {
+		if v < 0 {
+			errs = append(errs, name+" must not be negative")
+		}
+	}
+	validRange := func(name string, r Range) {
+		if r.Min < 0 || r.Max < r.Min {
+			errs = append(errs, name+" must satisfy 0 <= min <= max")
+		}
+	}
+
+	positive("player.maxVelocity", c.Player.MaxVelocity)
+	positive("bullet.velocity", c.Bullet.Velocity)
+	positive("bullet.ttl", c.Bullet.TTL)
+	positive("tank.ttl", c.Tank.TTL)
+	validRange("tower.aimInterval", c.Tower.AimInterval)
+	positive("tower.burstInterval", c.Tower.BurstInterval)
+	if c.Tower.BurstSize.Min < 1 || c.Tower.BurstSize.Max < c.Tower.BurstSize.Min {
+		errs = append(errs, "tower.burstSize must satisfy 1 <= min <= max")
+	}
+	notNegative("tower.spread", c.Tower.Spread)
+	notNegative("speedPowerUp.bonus", c.SpeedPowerUp.Bonus)
+	positive("speedPowerUp.ttl", c.SpeedPowerUp.TTL)
+	validRange("speedPowerUp.spawnInterval", c.SpeedPowerUp.SpawnInterval)
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// LoadConfig reads the config file at path. Missing values keep their defaults.
+func LoadConfig(path string) (Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("unable to read config: %w", err)
+	}
+	config := DefaultConfig()
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&config); err != nil {
+		return Config{}, fmt.Errorf("unable to decode config: %v", err)
+	}
+	if err := config.Validate(); err != nil {
+		return Config{}, fmt.Errorf("invalid config: %v", err)
+	}
+	return config, nil
+}
+
+// tune applies the config to an entity spawned from one of the built-in prefabs. The config only
+// fills in the fields that the prefab leaves out, so prefab files can override it.
+func (w *World) tune(prefab string, entity Entity) {
+	c := w.Components
+	set := w.prefabFields[prefab]
+	switch prefab {
+	case PrefabPlayer:
+		if velocity, ok := c.Velocities.Get(entity); ok && !set["velocities.max"] {
+			velocity.Max = w.config.Player.MaxVelocity
+		}
+	case PrefabBullet:
+		if velocity, ok := c.Velocities.Get(entity); ok && !set["velocities.linear"] {
+			velocity.Linear = physics.Vec2{X: w.config.Bullet.Velocity}
+		}
+		if health, ok := c.Healths.Get(entity); ok && !set["healths.ttl"] {
+			health.TTL = w.config.Bullet.TTL
+		}
+	case PrefabTank:
+		if health, ok := c.Healths.Get(entity); ok && !set["healths.ttl"] {
+			health.TTL = w.config.Tank.TTL
+		}
+	case PrefabSpeedPowerUp:
+		if health, ok := c.Healths.Get(entity); ok && !set["healths.ttl"] {
+			health.TTL = w.config.SpeedPowerUp.TTL
+		}
+	}
+}
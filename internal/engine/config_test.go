@@ -0,0 +1,42 @@
+package engine
+
+import (
+	"encoding/json"
+	"testing"
+
+	"cfichtmueller.com/htmx-game/internal/engine/physics"
+)
+
+func TestPrefabFieldsOverrideConfig(t *testing.T) {
+	w := NewWorld(1000, 600, 1)
+	w.config.Tank.TTL = 45
+	w.config.Bullet.TTL = 7
+
+	tank, _ := w.Spawn(PrefabTank, 0, 0, 0)
+	if health, _ := w.Components.Healths.Get(tank); health.TTL != 45 {
+		t.Errorf("expected the config to set the tank TTL to 45, got %v", health.TTL)
+	}
+
+	err := w.AddPrefabs(map[string]*Prefab{PrefabTank: {
+		Type: Tank,
+		Components: map[string]json.RawMessage{
+			"healths": json.RawMessage(`{"ages": true, "TTL": 20}`),
+		},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tank, _ = w.Spawn(PrefabTank, 0, 0, 0)
+	if health, _ := w.Components.Healths.Get(tank); health.TTL != 20 {
+		t.Errorf("expected the prefab to set the tank TTL to 20, got %v", health.TTL)
+	}
+
+	// Bullets fly in the direction they are spawned in.
+	bullet, _ := w.Spawn(PrefabBullet, 0, 0, physics.Deg90)
+	health, _ := w.Components.Healths.Get(bullet)
+	velocity, _ := w.Components.Velocities.Get(bullet)
+	expected := physics.Vec2{Y: DefaultConfig().Bullet.Velocity}
+	if health.TTL != 7 || velocity.Linear.Sub(expected).Len() > 1e-9 {
+		t.Errorf("expected the config to tune bullets, got ttl %v and velocity %v", health.TTL, velocity.Linear)
+	}
+}
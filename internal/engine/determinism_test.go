@@ -0,0 +1,88 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// scriptedEngine joins players with fixed ids, as random ids would end up in the snapshots.
+func scriptedEngine(t *testing.T, seed uint64, players ...string) *Engine {
+	t.Helper()
+	e := New(1000, 600, WithSeed(seed), WithTeams("red", "blue"))
+	if err := e.Init(); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range players {
+		cmd := Command{M: CommandJoin}
+		if err := e.apply(id, cmd); err != nil {
+			t.Fatal(err)
+		}
+		e.record(id, cmd)
+	}
+	return e
+}
+
+// script sends the inputs of tick to e.
+func script(e *Engine, tick int, players ...string) error {
+	for i, id := range players {
+		var err error
+		switch {
+		case tick%90 == 30*i:
+			err = e.Enqueue(id, Command{M: CommandSetRotation, V: float64(tick%7-3) / 3})
+		case tick%60 == 10*i:
+			err = e.Enqueue(id, Command{M: CommandSetVelocity, V: float64(tick%5) / 4})
+		case tick%400 == 399:
+			err = e.Enqueue(id, Command{M: CommandRespawn})
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func snapshotOf(t *testing.T, e *Engine) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	if err := e.WriteSnapshot(&b); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestSameSeedAndInputsGiveSameWorld(t *testing.T) {
+	players := []string{"a", "b", "c"}
+	first := scriptedEngine(t, 7, players...)
+	second := scriptedEngine(t, 7, players...)
+	other := scriptedEngine(t, 8, players...)
+	diverged := false
+	for tick := 0; tick < 1200; tick++ {
+		for _, e := range []*Engine{first, second, other} {
+			e.Lock()
+			err := script(e, tick, players...)
+			e.Unlock()
+			if err != nil {
+				t.Fatal(err)
+			}
+			e.Step(1)
+		}
+		a, b := snapshotOf(t, first), snapshotOf(t, second)
+		if !bytes.Equal(a, b) {
+			t.Fatalf("worlds diverged at tick %d:\n%s", tick+1, firstDifference(a, b))
+		}
+		diverged = diverged || !bytes.Equal(a, snapshotOf(t, other))
+	}
+	if !diverged {
+		t.Error("expected a different seed to give a different world")
+	}
+}
+
+func firstDifference(a, b []byte) string {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	from := max(0, i-80)
+	return fmt.Sprintf("%s\n%s", a[from:min(len(a), i+80)], b[from:min(len(b), i+80)])
+}
@@ -1,62 +1,347 @@
 package engine
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
 	"sync"
 	"time"
 
 	"cfichtmueller.com/htmx-game/internal/engine/physics"
 )
 
+const (
+	DefaultTickRate        = 30
+	DefaultMaxCatchUpSteps = 5
+	DefaultIdleTimeout     = time.Minute
+	DefaultRecordingWindow = 10 * time.Minute
+	MaxTimeScale           = 10
+)
+
 type Engine struct {
-	mu          sync.Mutex
-	World       *World
-	Fps         int
-	loopTicker  *time.Ticker
-	playerIndex map[string]Entity
+	mu              sync.Mutex
+	World           *World
+	Fps             int
+	tickRate        int
+	maxCatchUpSteps int
+	seed            uint64
+	prefabs         map[string]*Prefab
+	config          *Config
+	friendlyFire    bool
+	teams           []string
+	populated       bool
+	tick            uint64
+	recording       *Recording
+	prevRecording   *Recording
+	recordingWindow time.Duration
+	replay          *Recording
+	replayNext      int
+	paused          bool
+	timeScale       float64
+	stop            chan struct{}
+	done            chan struct{}
+	lockedAt        time.Time
+	lockHeld        *rolling
+	tickLockWait    *rolling
+	inputDelay      *rolling
+	inputSeq        uint64
+	idleTimeout     time.Duration
+	sessions        map[string]*Session
+}
+
+type Option func(e *Engine)
+
+// WithTickRate sets the number of fixed simulation steps per second.
+func WithTickRate(rate int) Option {
+	return func(e *Engine) {
+		if rate > 0 {
+			e.tickRate = rate
+		}
+	}
+}
+
+// WithSeed seeds the random source of the world. Engines with the same seed and the same inputs
+// produce identical worlds.
+func WithSeed(seed uint64) Option {
+	return func(e *Engine) {
+		e.seed = seed
+	}
+}
+
+// WithMaxCatchUpSteps limits how many steps the loop runs to catch up after a slow tick.
+// Simulation time that exceeds the limit is dropped.
+func WithMaxCatchUpSteps(n int) Option {
+	return func(e *Engine) {
+		if n > 0 {
+			e.maxCatchUpSteps = n
+		}
+	}
+}
+
+// WithConfig replaces the default config. It is validated when the engine is started.
+func WithConfig(config Config) Option {
+	return func(e *Engine) {
+		e.config = &config
+	}
+}
+
+// WithPrefabs adds prefabs to the world, replacing the default prefabs with the same name.
+// They are validated when the engine is started.
+func WithPrefabs(prefabs map[string]*Prefab) Option {
+	return func(e *Engine) {
+		e.prefabs = prefabs
+	}
+}
+
+// WithFriendlyFire lets teammates harm each other.
+func WithFriendlyFire(on bool) Option {
+	return func(e *Engine) {
+		e.friendlyFire = on
+	}
+}
+
+// WithTeams puts joining players on the team with the fewest players. Without teams,
+// players are hostile to everyone.
+func WithTeams(names ...string) Option {
+	return func(e *Engine) {
+		e.teams = names
+	}
+}
+
+// WithIdleTimeout sets how long a player may neither send input nor poll the field before
+// the player is removed.
+func WithIdleTimeout(d time.Duration) Option {
+	return func(e *Engine) {
+		if d > 0 {
+			e.idleTimeout = d
+		}
+	}
+}
+
+// WithRecordingWindow sets how much of the match a recording covers at least. Recordings cover
+// at most twice as much, older inputs are dropped.
+func WithRecordingWindow(d time.Duration) Option {
+	return func(e *Engine) {
+		if d > 0 {
+			e.recordingWindow = d
+		}
+	}
 }
 
-func New(width, height float64) *Engine {
-	world := NewWorld(width, height)
+func New(width, height float64, opts ...Option) *Engine {
+	e := &Engine{
+		tickRate:        DefaultTickRate,
+		maxCatchUpSteps: DefaultMaxCatchUpSteps,
+		seed:            rand.Uint64(),
+		idleTimeout:     DefaultIdleTimeout,
+		recordingWindow: DefaultRecordingWindow,
+		timeScale:       1,
+		sessions:        make(map[string]*Session),
+		lockHeld:        newRolling(),
+		tickLockWait:    newRolling(),
+		inputDelay:      newRolling(),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	world := NewWorld(width, height, e.seed)
+	world.friendlyFire = e.friendlyFire
+	world.teams = e.teams
+
+	world.AddSystem(NewInputSystem(e), Schedule{Name: SystemInput, Phase: PhaseInput})
 
-	// beware - the order of systems is important
+	world.AddSystem(NewSensingSystem(world), Schedule{Name: SystemSensing, Phase: PhaseAI, Before: []string{SystemBehavior}})
+	world.AddSystem(NewSpeedPowerUpSystem(world), Schedule{Name: SystemSpeedPowerUp, Phase: PhaseAI})
+	world.AddSystem(NewBehaviorSystem(), Schedule{Name: SystemBehavior, Phase: PhaseAI})
 
-	world.AddSystem(NewAutoMoveSystem())
-	world.AddSystem(NewMovementSystem())
+	world.AddSystem(NewAutoMoveSystem(), Schedule{Name: SystemAutoMove, Phase: PhaseMovement, Before: []string{SystemMovement}})
+	world.AddSystem(NewMovementSystem(), Schedule{Name: SystemMovement, Phase: PhaseMovement})
+	world.AddSystem(NewTransformSystem(), Schedule{Name: SystemTransform, Phase: PhaseMovement, After: []string{SystemMovement}})
 
-	collisionDetection := NewCollisionDetectionSystem()
+	collisionDetection := NewCollisionDetectionSystem(world)
 	collisionDetection.RegisterHandler(Bullet, Tank, NewBulletPlayerCollisionHandler(world))
 	collisionDetection.RegisterHandler(Bullet, Player, NewBulletPlayerCollisionHandler(world))
-	collisionDetection.RegisterHandler(Player, Tower, &PlayerTowerCollisionHandler{})
-	collisionDetection.RegisterHandler(Tank, Tower, &TankTowerCollisionHandler{})
+	collisionDetection.RegisterHandler(Player, Tower, NewPlayerTowerCollisionHandler(world))
 	collisionDetection.RegisterHandler(Player, SpeedPowerUp, NewPlayerPowerUpCollisionHandler(world, func(entity Entity, components *ComponentStorage) {
-		components.Velocities[entity].Max += 5
+		velocity, _ := components.Velocities.Get(entity)
+		velocity.Max += world.config.SpeedPowerUp.Bonus
 	}))
-	collisionDetection.RegisterHandler(Tank, Player, &TankPlayerCollisionHandler{})
+	collisionDetection.RegisterHandler(Tank, Player, NewTankPlayerCollisionHandler(world))
 
-	world.AddSystem(collisionDetection)
-	world.AddSystem(NewHealthSystem(world))
+	world.AddSystem(collisionDetection, Schedule{Name: SystemCollisionDetection, Phase: PhaseCollision})
+	world.AddSystem(NewRigidBodySystem(world), Schedule{Name: SystemRigidBody, Phase: PhaseCollision, After: []string{SystemCollisionDetection}})
+	world.AddSystem(NewHealthSystem(world), Schedule{Name: SystemHealth, Phase: PhaseHealth})
 
-	world.AddSystem(NewSensingSystem())
-	world.AddSystem(NewSpeedPowerUpSystem(world))
-	world.AddSystem(NewBehaviorSystem())
-
-	return &Engine{
-		World:       world,
-		loopTicker:  time.NewTicker(30 * time.Millisecond),
-		playerIndex: make(map[string]Entity),
-	}
+	e.World = world
+	return e
 }
 
 func (e *Engine) Lock() {
 	e.mu.Lock()
+	e.lockedAt = time.Now()
 }
 
 func (e *Engine) Unlock() {
+	e.lockHeld.add(int64(time.Since(e.lockedAt)))
 	e.mu.Unlock()
 }
 
-func (e *Engine) Start() {
-	PlaceInRaster(1, int((e.World.Width-100)/50), int((e.World.Height-100)/50), func(x, y int) {
+// TickRate returns the configured number of simulation steps per second.
+func (e *Engine) TickRate() int {
+	return e.tickRate
+}
+
+// Seed returns the seed of the world's random source.
+func (e *Engine) Seed() uint64 {
+	return e.seed
+}
+
+// Init schedules the systems, adds the prefabs and populates the world, unless it was restored
+// from a snapshot. Start calls it, call it directly to advance the world with Step only.
+func (e *Engine) Init() error {
+	if err := e.World.Schedule(); err != nil {
+		return fmt.Errorf("unable to schedule systems: %v", err)
+	}
+	if err := e.World.AddPrefabs(e.prefabs); err != nil {
+		return fmt.Errorf("unable to add prefabs: %v", err)
+	}
+	if e.config != nil {
+		if err := e.setConfig(*e.config); err != nil {
+			return err
+		}
+		e.config = nil
+	}
+	if !e.populated {
+		e.populate()
+	}
+	if e.replay == nil && e.recording == nil {
+		if err := e.startRecording(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var ErrRunning = errors.New("engine is already running")
+
+// Start initializes the world and runs the simulation loop until ctx is done or Stop is called.
+// The caller must not hold the lock.
+func (e *Engine) Start(ctx context.Context) error {
+	e.Lock()
+	defer e.Unlock()
+	if e.running() {
+		return ErrRunning
+	}
+	if err := e.Init(); err != nil {
+		return err
+	}
+	e.stop = make(chan struct{})
+	e.done = make(chan struct{})
+	go e.loop(ctx, e.stop, e.done)
+	return nil
+}
+
+// running reports whether the loop started by Start hasn't returned yet. The caller must hold the lock.
+func (e *Engine) running() bool {
+	if e.done == nil {
+		return false
+	}
+	select {
+	case <-e.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// Stop ends the simulation loop started by Start and waits for it to return.
+// The caller must not hold the lock.
+func (e *Engine) Stop() {
+	e.Lock()
+	stop, done := e.stop, e.done
+	e.stop = nil
+	e.done = nil
+	e.Unlock()
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+}
+
+// Pause stops advancing the world until Resume is called. The caller must hold the lock.
+func (e *Engine) Pause() {
+	e.paused = true
+}
+
+// Resume continues a paused world. The caller must hold the lock.
+func (e *Engine) Resume() {
+	e.paused = false
+}
+
+// Paused reports whether the world is paused. The caller must hold the lock.
+func (e *Engine) Paused() bool {
+	return e.paused
+}
+
+// SetTimeScale multiplies the time that passes in the world with each step.
+// The caller must hold the lock.
+func (e *Engine) SetTimeScale(f float64) error {
+	if e.replay != nil {
+		return ErrReplaying
+	}
+	cmd := Command{M: CommandTimeScale, V: f}
+	if err := e.apply("", cmd); err != nil {
+		return err
+	}
+	e.record("", cmd)
+	return nil
+}
+
+func (e *Engine) setTimeScale(f float64) error {
+	if f <= 0 || f > MaxTimeScale {
+		return fmt.Errorf("time scale must be greater than 0 and at most %d", MaxTimeScale)
+	}
+	e.timeScale = f
+	return nil
+}
+
+// SetConfig replaces the config. Entities that already exist keep their values, towers use the
+// new timings right away.
+// The caller must hold the lock.
+func (e *Engine) SetConfig(config Config) error {
+	if e.replay != nil {
+		return ErrReplaying
+	}
+	if err := e.setConfig(config); err != nil {
+		return err
+	}
+	e.recordInput(Input{Command: Command{M: CommandConfig}, Config: &config})
+	return nil
+}
+
+func (e *Engine) setConfig(config Config) error {
+	if err := config.Validate(); err != nil {
+		return fmt.Errorf("invalid config: %v", err)
+	}
+	e.World.config = config
+	return nil
+}
+
+// Config returns the current config. The caller must hold the lock.
+func (e *Engine) Config() Config {
+	return e.World.config
+}
+
+// TimeScale returns the current time scale. The caller must hold the lock.
+func (e *Engine) TimeScale() float64 {
+	return e.timeScale
+}
+
+func (e *Engine) populate() {
+	e.World.PlaceInRaster(1, int((e.World.Width-100)/50), int((e.World.Height-100)/50), func(x, y int) {
 		SpawnTankShelter(
 			e.World,
 			float64(50+x*50),
@@ -64,44 +349,91 @@ func (e *Engine) Start() {
 			physics.Deg90,
 		)
 	})
-	PlaceInRaster(10, int((e.World.Width-100)/50), int((e.World.Height-100)/50), func(x, y int) {
+	e.World.PlaceInRaster(10, int((e.World.Width-100)/50), int((e.World.Height-100)/50), func(x, y int) {
 		SpawnTower(
 			e.World,
 			float64(50+x*50),
 			float64(50+y*50),
 		)
 	})
+	e.populated = true
+}
 
-	go func(e *Engine) {
-		last := time.Now().UnixMilli()
-		for {
-			<-e.loopTicker.C
-			now := time.Now().UnixMilli()
-			delta := float64(now-last) / 1000
-			last = now
-			e.mu.Lock()
-			if (now - last) != 0 {
-				e.Fps = int(1000 / (now - last))
-			}
-			e.World.Update(delta)
-			e.mu.Unlock()
-		}
-	}(e)
+// Step advances the world by n fixed steps. It doesn't require the loop to be started
+// and ignores Pause.
+func (e *Engine) Step(n int) {
+	e.Lock()
+	defer e.Unlock()
+	for i := 0; i < n; i++ {
+		e.step()
+	}
+}
+
+func (e *Engine) step() {
+	if e.replay != nil && !e.feedReplay() {
+		return
+	}
+	e.World.Update(e.stepDuration().Seconds() * e.timeScale)
+	e.tick++
+	e.rotateRecording()
 }
 
-func (e *Engine) SpawnPlayer() string {
-	id := randomId()
-	p := SpawnPlayer(
-		e.World,
-		e.World.Width/2,
-		e.World.Height/2,
-		physics.Deg0,
-	)
-	e.playerIndex[id] = p
-	return id
+// Tick returns the number of steps since the engine was initialized. The caller must hold the lock.
+func (e *Engine) Tick() uint64 {
+	return e.tick
 }
 
-func (e *Engine) PlayerWithId(id string) (Entity, bool) {
-	entity, ok := e.playerIndex[id]
-	return entity, ok
+func (e *Engine) stepDuration() time.Duration {
+	return time.Second / time.Duration(e.tickRate)
+}
+
+func (e *Engine) loop(ctx context.Context, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	stepDuration := e.stepDuration()
+	ticker := time.NewTicker(stepDuration)
+	defer ticker.Stop()
+
+	last := time.Now()
+	windowStart := last
+	windowSteps := 0
+	var accumulator time.Duration
+
+	for {
+		var now time.Time
+		select {
+		case <-stop:
+			return
+		case <-ctx.Done():
+			return
+		case now = <-ticker.C:
+		}
+
+		accumulator += now.Sub(last)
+		last = now
+
+		waitStart := time.Now()
+		e.Lock()
+		e.tickLockWait.add(int64(e.lockedAt.Sub(waitStart)))
+		if e.paused {
+			accumulator = 0
+		}
+		steps := 0
+		for accumulator >= stepDuration && steps < e.maxCatchUpSteps {
+			e.step()
+			accumulator -= stepDuration
+			steps++
+		}
+		if accumulator >= stepDuration {
+			accumulator = 0
+		}
+
+		windowSteps += steps
+		if elapsed := now.Sub(windowStart); elapsed >= time.Second {
+			e.Fps = int(float64(windowSteps)/elapsed.Seconds() + 0.5)
+			windowStart = now
+			windowSteps = 0
+			e.reapIdleSessions(now)
+		}
+		e.Unlock()
+	}
 }
@@ -0,0 +1,59 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestStartTwice(t *testing.T) {
+	e := New(1000, 600, WithSeed(1))
+	if err := e.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer e.Stop()
+	if err := e.Start(context.Background()); !errors.Is(err, ErrRunning) {
+		t.Errorf("expected ErrRunning, got %v", err)
+	}
+}
+
+func TestRestartAfterContextDone(t *testing.T) {
+	e := New(1000, 600, WithSeed(1))
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := e.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	deadline := time.Now().Add(time.Second)
+	for {
+		err := e.Start(context.Background())
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, ErrRunning) || time.Now().After(deadline) {
+			t.Fatalf("expected to restart once the loop returned, got %v", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	e.Stop()
+}
+
+func TestStopLeavesNoGoroutines(t *testing.T) {
+	before := runtime.NumGoroutine()
+	engines := make([]*Engine, 20)
+	for i := range engines {
+		engines[i] = New(1000, 600, WithSeed(uint64(i)))
+		if err := engines[i].Start(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, e := range engines {
+		e.Stop()
+		e.Stop()
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("expected %d goroutines after stopping, got %d", before, after)
+	}
+}
@@ -1,7 +1,12 @@
 package engine
 
+import "fmt"
+
 type EntityType int
 
+// NoEntityType is the type of entities that don't exist.
+const NoEntityType EntityType = -1
+
 const (
 	Player EntityType = iota
 	Bullet
@@ -11,4 +16,59 @@ const (
 	SpeedPowerUp
 )
 
+var entityTypeNames = map[EntityType]string{
+	Player:       "player",
+	Bullet:       "bullet",
+	Tank:         "tank",
+	TankShelter:  "tankShelter",
+	Tower:        "tower",
+	SpeedPowerUp: "speedPowerUp",
+}
+
+func (t EntityType) String() string {
+	if name, ok := entityTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("entityType(%d)", int(t))
+}
+
+func ParseEntityType(s string) (EntityType, error) {
+	for t, name := range entityTypeNames {
+		if name == s {
+			return t, nil
+		}
+	}
+	return NoEntityType, fmt.Errorf("unknown entity type %s", s)
+}
+
+func (t EntityType) MarshalText() ([]byte, error) {
+	if _, ok := entityTypeNames[t]; !ok {
+		return nil, fmt.Errorf("unknown entity type %d", int(t))
+	}
+	return []byte(t.String()), nil
+}
+
+func (t *EntityType) UnmarshalText(b []byte) error {
+	parsed, err := ParseEntityType(string(b))
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
+}
+
+// Entity is a handle made of an index and a generation. Indices are reused once an entity is removed,
+// the generation tells a stale handle apart from the entity that reuses its index.
 type Entity int64
+
+func newEntity(index, generation uint32) Entity {
+	return Entity(int64(generation)<<32 | int64(index))
+}
+
+func (e Entity) Index() uint32 {
+	return uint32(e)
+}
+
+func (e Entity) Generation() uint32 {
+	return uint32(e >> 32)
+}
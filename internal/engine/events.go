@@ -0,0 +1,79 @@
+package engine
+
+import "reflect"
+
+// NoEntity is never a valid entity. It's used in events when there is no entity to refer to.
+const NoEntity Entity = 0
+
+// EntityDied is published when an entity is killed or its time to live runs out.
+// Killer is NoEntity and KillerType is NoEntityType if nothing killed the entity.
+type EntityDied struct {
+	Entity     Entity
+	Type       EntityType
+	Killer     Entity
+	KillerType EntityType
+}
+
+// EntityRemoved is published when an entity is removed from the world at the end of an update.
+type EntityRemoved struct {
+	Entity Entity
+	Type   EntityType
+}
+
+type PowerUpCollected struct {
+	Player  Entity
+	PowerUp Entity
+	Type    EntityType
+}
+
+type BulletFired struct {
+	Bullet  Entity
+	Shooter Entity
+}
+
+type TankSpawned struct {
+	Tank    Entity
+	Shelter Entity
+}
+
+type subscription struct {
+	id      int
+	handler any
+}
+
+// EventBus delivers events to subscribers by event type. Events are delivered synchronously while
+// they are published, which is usually during a world update with the engine locked.
+type EventBus struct {
+	nextId   int
+	handlers map[reflect.Type][]subscription
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{
+		handlers: make(map[reflect.Type][]subscription),
+	}
+}
+
+// Subscribe calls handler for every published event of type E. The returned function ends the subscription.
+func Subscribe[E any](bus *EventBus, handler func(event E)) func() {
+	t := reflect.TypeFor[E]()
+	bus.nextId++
+	id := bus.nextId
+	bus.handlers[t] = append(bus.handlers[t], subscription{id: id, handler: handler})
+
+	return func() {
+		subscriptions := bus.handlers[t]
+		for i, s := range subscriptions {
+			if s.id == id {
+				bus.handlers[t] = append(subscriptions[:i:i], subscriptions[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+func Publish[E any](bus *EventBus, event E) {
+	for _, s := range bus.handlers[reflect.TypeFor[E]()] {
+		s.handler.(func(E))(event)
+	}
+}
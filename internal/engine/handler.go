@@ -1,44 +1,36 @@
 package engine
 
-import (
-	"cfichtmueller.com/htmx-game/internal/engine/physics"
-)
-
-type BulletTankCollisionHandler struct {
+type BulletPlayerCollisionHandler struct {
 	world *World
 }
 
-func NewBulletTankCollisionHandler(world *World) *BulletPlayerCollisionHandler {
+func NewBulletPlayerCollisionHandler(world *World) *BulletPlayerCollisionHandler {
 	return &BulletPlayerCollisionHandler{world: world}
 }
 
-func (h *BulletTankCollisionHandler) HandleCollision(entityA, entityB Entity, components *ComponentStorage, dt float64) {
+// HandleCollision removes the bullet and kills the target. Bullets pass through teammates
+// unless friendly fire is on.
+func (h *BulletPlayerCollisionHandler) HandleCollision(entityA, entityB Entity, components *ComponentStorage, dt float64) {
+	if !h.world.CanHarm(entityA, entityB) {
+		return
+	}
 	h.world.RemoveEntity(entityA)
-	components.Healths[entityB].Dead = true
-	components.Velocities[entityB].Current = 0
+	h.world.Kill(entityB, entityA)
 }
 
-type BulletPlayerCollisionHandler struct {
+type PlayerTowerCollisionHandler struct {
 	world *World
 }
 
-func NewBulletPlayerCollisionHandler(world *World) *BulletPlayerCollisionHandler {
-	return &BulletPlayerCollisionHandler{world: world}
-}
-
-func (h *BulletPlayerCollisionHandler) HandleCollision(entityA, entityB Entity, components *ComponentStorage, dt float64) {
-	h.world.RemoveEntity(entityA)
-	components.Healths[entityB].Dead = true
+func NewPlayerTowerCollisionHandler(world *World) *PlayerTowerCollisionHandler {
+	return &PlayerTowerCollisionHandler{world: world}
 }
 
-type PlayerTowerCollisionHandler struct{}
-
 func (h *PlayerTowerCollisionHandler) HandleCollision(entityA, entityB Entity, components *ComponentStorage, dt float64) {
-	playerHealth := components.Healths[entityA]
-	if playerHealth.Dead {
+	if IsEntityDead(h.world, entityA) || !h.world.CanHarm(entityA, entityB) {
 		return
 	}
-	components.Healths[entityB].Dead = true
+	h.world.Kill(entityB, entityA)
 }
 
 type PlayerPowerUpCollisionHandler struct {
@@ -56,25 +48,20 @@ func NewPlayerPowerUpCollisionHandler(world *World, f func(entity Entity, compon
 func (h *PlayerPowerUpCollisionHandler) HandleCollision(entityA, entityB Entity, components *ComponentStorage, dt float64) {
 	h.f(entityA, components)
 	h.world.RemoveEntity(entityB)
+	Publish(h.world.Events, PowerUpCollected{Player: entityA, PowerUp: entityB, Type: h.world.EntityType(entityB)})
 }
 
-type TankPlayerCollisionHandler struct{}
+type TankPlayerCollisionHandler struct {
+	world *World
+}
+
+func NewTankPlayerCollisionHandler(world *World) *TankPlayerCollisionHandler {
+	return &TankPlayerCollisionHandler{world: world}
+}
 
 func (h *TankPlayerCollisionHandler) HandleCollision(entityA, entityB Entity, components *ComponentStorage, dt float64) {
-	tankHealth := components.Healths[entityA]
-	if tankHealth.Dead {
+	if IsEntityDead(h.world, entityA) || !h.world.CanHarm(entityA, entityB) {
 		return
 	}
-	health := components.Healths[entityB]
-	health.Dead = true
-}
-
-type TankTowerCollisionHandler struct{}
-
-func (h *TankTowerCollisionHandler) HandleCollision(entityA, entityB Entity, components *ComponentStorage, dt float64) {
-	position := components.Positions[entityA]
-	velocity := components.Velocities[entityA]
-
-	physics.Move2(position, -velocity.Current, dt)
-	position.Direction += physics.Deg180
+	h.world.Kill(entityB, entityA)
 }
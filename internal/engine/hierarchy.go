@@ -0,0 +1,58 @@
+package engine
+
+import "fmt"
+
+const maxHierarchyDepth = 16
+
+// Attach makes child follow parent, see Parent.
+func (w *World) Attach(child Entity, parent Parent) error {
+	if !w.Alive(child) || !w.Alive(parent.Entity) {
+		return ErrEntityNotAlive
+	}
+	if parent.Policy == "" {
+		parent.Policy = RemoveChildren
+	}
+	if parent.Policy != RemoveChildren && parent.Policy != DetachChildren {
+		return fmt.Errorf("unknown removal policy %s", parent.Policy)
+	}
+	depth := 1
+	for ancestor := parent.Entity; ; depth++ {
+		if ancestor == child {
+			return fmt.Errorf("entity %d can't be attached to its own descendant %d", child, parent.Entity)
+		}
+		next, ok := w.Components.Parents.Get(ancestor)
+		if !ok {
+			break
+		}
+		ancestor = next.Entity
+	}
+	if depth+w.subtreeDepth(child) > maxHierarchyDepth {
+		return fmt.Errorf("hierarchy would be deeper than %d", maxHierarchyDepth)
+	}
+	w.Components.Parents.Add(child, &parent)
+	return nil
+}
+
+// Detach removes child from its parent. The child keeps its current position.
+func (w *World) Detach(child Entity) {
+	w.Components.Parents.Remove(child)
+}
+
+// Children returns the entities directly attached to entity.
+func (w *World) Children(entity Entity) []Entity {
+	var children []Entity
+	w.Components.Parents.Each(func(child Entity, parent *Parent) {
+		if parent.Entity == entity {
+			children = append(children, child)
+		}
+	})
+	return children
+}
+
+func (w *World) subtreeDepth(entity Entity) int {
+	depth := 0
+	for _, child := range w.Children(entity) {
+		depth = max(depth, 1+w.subtreeDepth(child))
+	}
+	return depth
+}
@@ -0,0 +1,40 @@
+package engine
+
+import (
+	"testing"
+
+	"cfichtmueller.com/htmx-game/internal/engine/physics"
+)
+
+func TestTransformSystemKeepsQueryOrdered(t *testing.T) {
+	w := NewWorld(1000, 600, 1)
+	spawn := func(x float64) Entity {
+		entity := w.AddEntity(Tower)
+		w.Components.Positions.Add(entity, physics.NewPosition(x, 0, 0))
+		return entity
+	}
+	root, a, b, c := spawn(0), spawn(10), spawn(20), spawn(30)
+	for _, link := range [][2]Entity{{c, root}, {a, c}, {b, root}} {
+		if err := w.Attach(link[0], Parent{Entity: link[1], OffsetX: 5}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	system := NewTransformSystem()
+	system.Update(w.Entities, w.Components, 0)
+	w.Detach(b)
+	w.Detach(c)
+
+	got := w.Query(With[Parent], With[physics.Position]).Entities()
+	if len(got) != 1 || got[0] != a {
+		t.Fatalf("expected only %d to be attached, got %v", a, got)
+	}
+	cPos, _ := w.Components.Positions.Get(c)
+	cPos.X = 30
+	system.Update(w.Entities, w.Components, 0)
+
+	pos, _ := w.Components.Positions.Get(a)
+	if pos.X != 35 {
+		t.Errorf("expected a to follow c to x=35, got %v", pos.X)
+	}
+}
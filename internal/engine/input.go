@@ -0,0 +1,94 @@
+package engine
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+// MaxQueuedCommands limits the commands a player may queue between two steps.
+const MaxQueuedCommands = 32
+
+var (
+	ErrInvalidCommand = errors.New("invalid command")
+	ErrQueueFull      = errors.New("command queue is full")
+)
+
+// QueuedCommand is a player command waiting for the next step.
+type QueuedCommand struct {
+	Command
+	Received time.Time
+	// Seq orders the commands of all players by arrival.
+	Seq uint64
+}
+
+// Enqueue validates a command sent by the player and queues it. The input system applies and
+// records queued commands at the start of the next step. The caller must hold the lock.
+func (e *Engine) Enqueue(id string, cmd Command) error {
+	switch cmd.M {
+	case CommandSetVelocity, CommandSetRotation, CommandRespawn:
+	default:
+		return fmt.Errorf("%w %s", ErrUnknownCommand, cmd.M)
+	}
+	if math.IsNaN(cmd.V) || math.IsInf(cmd.V, 0) {
+		return fmt.Errorf("%w: %s needs a finite value", ErrInvalidCommand, cmd.M)
+	}
+	if e.replay != nil {
+		return ErrReplaying
+	}
+	session, ok := e.sessions[id]
+	if !ok {
+		return fmt.Errorf("unknown player %s", id)
+	}
+	if len(session.Queue) >= MaxQueuedCommands {
+		return ErrQueueFull
+	}
+	e.inputSeq++
+	session.Queue = append(session.Queue, QueuedCommand{Command: cmd, Received: time.Now(), Seq: e.inputSeq})
+	return nil
+}
+
+// drainInputs applies the queued commands of all players in the order they arrived, so the
+// outcome doesn't depend on the player ids. Commands that no longer apply, e.g. because the
+// player died, are dropped.
+func (e *Engine) drainInputs() {
+	type pending struct {
+		id  string
+		cmd QueuedCommand
+	}
+	queued := make([]pending, 0)
+	for id, session := range e.sessions {
+		for _, cmd := range session.Queue {
+			queued = append(queued, pending{id: id, cmd: cmd})
+		}
+		session.Queue = session.Queue[:0]
+	}
+	slices.SortFunc(queued, func(a, b pending) int {
+		return cmp.Compare(a.cmd.Seq, b.cmd.Seq)
+	})
+
+	now := time.Now()
+	for _, p := range queued {
+		e.inputDelay.add(int64(now.Sub(p.cmd.Received)))
+		if err := e.apply(p.id, p.cmd.Command); err != nil {
+			continue
+		}
+		e.record(p.id, p.cmd.Command)
+	}
+}
+
+// InputSystem applies the commands that players queued since the last step.
+type InputSystem struct {
+	engine *Engine
+}
+
+func NewInputSystem(engine *Engine) *InputSystem {
+	return &InputSystem{engine: engine}
+}
+
+func (s *InputSystem) Update(entities []Entity, components *ComponentStorage, dt float64) {
+	s.engine.drainInputs()
+}
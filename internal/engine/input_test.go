@@ -0,0 +1,45 @@
+package engine
+
+import "testing"
+
+func TestDrainInputsInArrivalOrder(t *testing.T) {
+	e := New(1000, 600, WithSeed(1))
+	if err := e.World.Schedule(); err != nil {
+		t.Fatal(err)
+	}
+	first, second := e.SpawnPlayer(), e.SpawnPlayer()
+	if first < second {
+		first, second = second, first
+	}
+	// The player with the greater id respawns first.
+	for _, id := range []string{first, second} {
+		if err := e.Respawn(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	e.step()
+
+	a, _ := e.PlayerWithId(first)
+	b, _ := e.PlayerWithId(second)
+	if a.Index() >= b.Index() {
+		t.Errorf("expected %s to respawn before %s, got indices %d and %d", first, second, a.Index(), b.Index())
+	}
+}
+
+func TestEnqueueRejectsInvalidCommands(t *testing.T) {
+	e := New(1000, 600, WithSeed(1))
+	id := e.SpawnPlayer()
+	for _, cmd := range []Command{{M: "jump"}, {M: CommandJoin}} {
+		if err := e.Enqueue(id, cmd); err == nil {
+			t.Errorf("expected %s to be rejected", cmd.M)
+		}
+	}
+	for i := 0; i < MaxQueuedCommands; i++ {
+		if err := e.Enqueue(id, Command{M: CommandSetVelocity, V: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := e.Enqueue(id, Command{M: CommandSetVelocity, V: 1}); err != ErrQueueFull {
+		t.Errorf("expected ErrQueueFull, got %v", err)
+	}
+}
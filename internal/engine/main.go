@@ -3,7 +3,6 @@ package engine
 import (
 	"crypto/rand"
 	"fmt"
-	mr "math/rand"
 	"strings"
 )
 
@@ -28,20 +27,20 @@ func randomId() string {
 
 type IFunc func() int
 
-func irandom(lower, upper int) int {
-	return lower + int(float64(upper-lower)*mr.Float64())
+func (w *World) irandom(lower, upper int) int {
+	return lower + int(float64(upper-lower)*w.rand.Float64())
 }
 
-func irandomF(lower, upper int) IFunc {
-	return func() int { return irandom(lower, upper) }
+func (w *World) irandomF(lower, upper int) IFunc {
+	return func() int { return w.irandom(lower, upper) }
 }
 
 type FFunc func() float64
 
-func frandom(lower, upper float64) float64 {
-	return lower + (upper-lower)*mr.Float64()
+func (w *World) frandom(lower, upper float64) float64 {
+	return lower + (upper-lower)*w.rand.Float64()
 }
 
-func frandomF(lower, upper float64) FFunc {
-	return func() float64 { return frandom(lower, upper) }
+func (w *World) frandomF(lower, upper float64) FFunc {
+	return func() float64 { return w.frandom(lower, upper) }
 }
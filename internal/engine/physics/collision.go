@@ -1,8 +1,171 @@
 package physics
 
-func Collides(p1 *Position, s1 *Rectangle, p2 *Position, s2 *Rectangle) bool {
-	return p1.X < p2.X+s2.W &&
-		p1.X+s1.W > p2.X &&
-		p1.Y < p2.Y+s2.H &&
-		p1.Y+s1.H > p2.Y
+import "math"
+
+// Shape is a collision shape. Shapes are centered on the position and face up, like sprites:
+// they are rotated by the position's direction plus Deg90.
+type Shape interface {
+	// Radius returns the radius of the smallest circle around the center that contains the shape.
+	Radius() float64
+	place(at *Position) placedShape
+}
+
+// Circle is a circle with radius R. Its rotation doesn't matter.
+type Circle struct {
+	R float64
+}
+
+// Polygon is a convex polygon.
+type Polygon struct {
+	Points []Vec2
+}
+
+// placedShape is a shape in world coordinates. Circles have no points.
+type placedShape struct {
+	center Vec2
+	radius float64
+	points []Vec2
+}
+
+func (c Circle) Radius() float64 {
+	return c.R
+}
+
+func (r Rectangle) Radius() float64 {
+	return Vec2{X: r.W / 2, Y: r.H / 2}.Len()
+}
+
+func (p Polygon) Radius() float64 {
+	radius := 0.0
+	for _, point := range p.Points {
+		radius = math.Max(radius, point.Len())
+	}
+	return radius
+}
+
+func (c Circle) place(at *Position) placedShape {
+	return placedShape{center: at.Vec2, radius: c.R}
+}
+
+// place treats the rectangle as a box of width W and length H.
+func (r Rectangle) place(at *Position) placedShape {
+	w, h := r.W/2, r.H/2
+	return Polygon{Points: []Vec2{{X: -w, Y: -h}, {X: w, Y: -h}, {X: w, Y: h}, {X: -w, Y: h}}}.place(at)
+}
+
+func (p Polygon) place(at *Position) placedShape {
+	rotation := at.Direction + Deg90
+	points := make([]Vec2, len(p.Points))
+	for i, point := range p.Points {
+		points[i] = at.Add(point.Rotate(rotation))
+	}
+	return placedShape{center: at.Vec2, points: points}
+}
+
+// Convex reports whether the polygon has at least three points and is convex.
+func (p Polygon) Convex() bool {
+	n := len(p.Points)
+	if n < 3 {
+		return false
+	}
+	sign := 0.0
+	for i := range p.Points {
+		a, b, c := p.Points[i], p.Points[(i+1)%n], p.Points[(i+2)%n]
+		cross := b.Sub(a).Cross(c.Sub(b))
+		if cross == 0 {
+			continue
+		}
+		if sign != 0 && (cross > 0) != (sign > 0) {
+			return false
+		}
+		sign = cross
+	}
+	return sign != 0
+}
+
+// Collides reports whether the shapes overlap. Touching shapes don't collide.
+func Collides(a Shape, pa *Position, b Shape, pb *Position) bool {
+	_, ok := Collide(a, pa, b, pb)
+	return ok
+}
+
+// Collide tests the shapes with the separating axis theorem. If they overlap, it returns the
+// minimum translation vector that moves b out of a.
+func Collide(a Shape, pa *Position, b Shape, pb *Position) (Vec2, bool) {
+	if pb.Sub(pa.Vec2).Len() >= a.Radius()+b.Radius() {
+		return Vec2{}, false
+	}
+	sa, sb := a.place(pa), b.place(pb)
+
+	axes := make([]Vec2, 0, len(sa.points)+len(sb.points)+1)
+	axes = appendEdgeNormals(axes, sa.points)
+	axes = appendEdgeNormals(axes, sb.points)
+	switch {
+	case sa.points == nil && sb.points == nil:
+		axes = append(axes, sb.center.Sub(sa.center))
+	case sa.points == nil:
+		axes = append(axes, closest(sb.points, sa.center).Sub(sa.center))
+	case sb.points == nil:
+		axes = append(axes, sb.center.Sub(closest(sa.points, sb.center)))
+	}
+
+	depth := math.Inf(1)
+	var normal Vec2
+	for _, axis := range axes {
+		axis = axis.Normalize()
+		if axis == (Vec2{}) {
+			continue
+		}
+		minA, maxA := sa.project(axis)
+		minB, maxB := sb.project(axis)
+		overlap := math.Min(maxA, maxB) - math.Max(minA, minB)
+		if overlap <= 0 {
+			return Vec2{}, false
+		}
+		if overlap < depth {
+			depth = overlap
+			normal = axis
+		}
+	}
+	if normal == (Vec2{}) {
+		// Concentric circles: any direction separates them.
+		normal = Vec2{X: 1}
+		depth = sa.radius + sb.radius
+	}
+	if sb.center.Sub(sa.center).Dot(normal) < 0 {
+		normal = normal.Scale(-1)
+	}
+	return normal.Scale(depth), true
+}
+
+func (s placedShape) project(axis Vec2) (float64, float64) {
+	if s.points == nil {
+		c := s.center.Dot(axis)
+		return c - s.radius, c + s.radius
+	}
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, p := range s.points {
+		d := p.Dot(axis)
+		lo = math.Min(lo, d)
+		hi = math.Max(hi, d)
+	}
+	return lo, hi
+}
+
+func appendEdgeNormals(axes []Vec2, points []Vec2) []Vec2 {
+	for i, p := range points {
+		edge := points[(i+1)%len(points)].Sub(p)
+		axes = append(axes, Vec2{X: -edge.Y, Y: edge.X})
+	}
+	return axes
+}
+
+func closest(points []Vec2, to Vec2) Vec2 {
+	best := points[0]
+	for _, p := range points[1:] {
+		if p.Sub(to).Len() < best.Sub(to).Len() {
+			best = p
+		}
+	}
+	return best
 }
@@ -0,0 +1,109 @@
+package physics
+
+import (
+	"math"
+	"testing"
+)
+
+// up makes shapes face up, so they aren't rotated.
+const up = -Deg90
+
+var triangle = Polygon{Points: []Vec2{{X: 0, Y: -15}, {X: 12, Y: 15}, {X: -12, Y: 15}}}
+
+func TestCollides(t *testing.T) {
+	tests := []struct {
+		name   string
+		a      Shape
+		pa     *Position
+		b      Shape
+		pb     *Position
+		expect bool
+	}{
+		{"boxes overlap", Rectangle{W: 20, H: 20}, NewPosition(0, 0, up), Rectangle{W: 20, H: 20}, NewPosition(15, 5, up), true},
+		{"boxes apart", Rectangle{W: 20, H: 20}, NewPosition(0, 0, up), Rectangle{W: 20, H: 20}, NewPosition(25, 0, up), false},
+		{"boxes touch", Rectangle{W: 20, H: 20}, NewPosition(0, 0, up), Rectangle{W: 20, H: 20}, NewPosition(20, 0, up), false},
+		{"box corners apart", Rectangle{W: 20, H: 20}, NewPosition(0, 0, up), Rectangle{W: 20, H: 20}, NewPosition(21, 21, up), false},
+		// A box rotated by 45° reaches 14.1 from its center.
+		{"rotated box reaches", Rectangle{W: 20, H: 20}, NewPosition(0, 0, up), Rectangle{W: 20, H: 20}, NewPosition(24, 0, up+Deg45), true},
+		{"unrotated box doesn't", Rectangle{W: 20, H: 20}, NewPosition(0, 0, up), Rectangle{W: 20, H: 20}, NewPosition(24, 0, up), false},
+		// A long box facing up is narrow along x, facing right it is long along x.
+		{"long box facing up", Rectangle{W: 10, H: 40}, NewPosition(0, 0, up), Circle{R: 5}, NewPosition(12, 0, 0), false},
+		{"long box facing right", Rectangle{W: 10, H: 40}, NewPosition(0, 0, Deg0), Circle{R: 5}, NewPosition(12, 0, 0), true},
+		{"circles overlap", Circle{R: 5}, NewPosition(0, 0, 0), Circle{R: 5}, NewPosition(8, 0, 0), true},
+		{"circles touch", Circle{R: 5}, NewPosition(0, 0, 0), Circle{R: 5}, NewPosition(10, 0, 0), false},
+		{"polygon tip hits circle", triangle, NewPosition(0, 0, up), Circle{R: 5}, NewPosition(0, -18, 0), true},
+		{"polygon tip misses circle", triangle, NewPosition(0, 0, up), Circle{R: 5}, NewPosition(0, -21, 0), false},
+		// Beside the tip, the slanted edge is further in than the bounding box.
+		{"circle beside the tip", Circle{R: 3}, NewPosition(9, -12, 0), triangle, NewPosition(0, 0, up), false},
+		{"polygon facing down", triangle, NewPosition(0, 0, Deg90), Circle{R: 5}, NewPosition(0, 18, 0), true},
+		{"polygon and box", triangle, NewPosition(0, 0, up), Rectangle{W: 10, H: 10}, NewPosition(0, 19, up), true},
+	}
+	for _, tt := range tests {
+		if got := Collides(tt.a, tt.pa, tt.b, tt.pb); got != tt.expect {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.expect)
+		}
+		if got := Collides(tt.b, tt.pb, tt.a, tt.pa); got != tt.expect {
+			t.Errorf("%s swapped: got %v, want %v", tt.name, got, tt.expect)
+		}
+	}
+}
+
+func TestCollideTranslation(t *testing.T) {
+	tests := []struct {
+		name string
+		a    Shape
+		pa   *Position
+		b    Shape
+		pb   *Position
+		mtv  Vec2
+	}{
+		{"box to the right", Rectangle{W: 20, H: 20}, NewPosition(0, 0, up), Rectangle{W: 20, H: 20}, NewPosition(15, 2, up), Vec2{X: 5}},
+		{"box to the left", Rectangle{W: 20, H: 20}, NewPosition(15, 2, up), Rectangle{W: 20, H: 20}, NewPosition(0, 0, up), Vec2{X: -5}},
+		{"box below", Rectangle{W: 20, H: 20}, NewPosition(0, 0, up), Rectangle{W: 20, H: 20}, NewPosition(1, 17, up), Vec2{Y: 3}},
+		{"circles", Circle{R: 5}, NewPosition(0, 0, 0), Circle{R: 5}, NewPosition(0, -8, 0), Vec2{Y: -2}},
+		{"concentric circles", Circle{R: 5}, NewPosition(0, 0, 0), Circle{R: 3}, NewPosition(0, 0, 0), Vec2{X: 8}},
+		{"circle on a box", Rectangle{W: 20, H: 20}, NewPosition(0, 0, up), Circle{R: 5}, NewPosition(0, -13, 0), Vec2{Y: -2}},
+		// Past a rotated corner, the shallowest way out is along an edge normal.
+		{"circle at a rotated corner", Rectangle{W: 20, H: 20}, NewPosition(0, 0, up+Deg45), Circle{R: 1}, NewPosition(14, 0, 0), Vec2{X: 11/math.Sqrt2 - 7, Y: 7 - 11/math.Sqrt2}},
+	}
+	for _, tt := range tests {
+		mtv, ok := Collide(tt.a, tt.pa, tt.b, tt.pb)
+		if !ok || !nearVec(mtv, tt.mtv) {
+			t.Errorf("%s: got %v %v, want %v", tt.name, ok, mtv, tt.mtv)
+			continue
+		}
+		// Moving b by the translation separates the shapes.
+		moved := &Position{Vec2: tt.pb.Add(mtv.Scale(1.001)), Direction: tt.pb.Direction}
+		if Collides(tt.a, tt.pa, tt.b, moved) {
+			t.Errorf("%s: still colliding after moving by %v", tt.name, mtv)
+		}
+	}
+}
+
+func TestConvex(t *testing.T) {
+	tests := []struct {
+		name    string
+		polygon Polygon
+		expect  bool
+	}{
+		{"triangle", triangle, true},
+		{"clockwise square", Polygon{Points: []Vec2{{X: 0, Y: 0}, {X: 0, Y: 1}, {X: 1, Y: 1}, {X: 1, Y: 0}}}, true},
+		{"arrow", Polygon{Points: []Vec2{{X: 0, Y: -10}, {X: 10, Y: 10}, {X: 0, Y: 0}, {X: -10, Y: 10}}}, false},
+		{"line", Polygon{Points: []Vec2{{X: 0, Y: 0}, {X: 1, Y: 1}}}, false},
+		{"collinear", Polygon{Points: []Vec2{{X: 0, Y: 0}, {X: 1, Y: 1}, {X: 2, Y: 2}}}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.polygon.Convex(); got != tt.expect {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.expect)
+		}
+	}
+}
+
+func TestRadius(t *testing.T) {
+	if r := (Rectangle{W: 6, H: 8}).Radius(); !near(r, 5) {
+		t.Errorf("rectangle: got %v, want 5", r)
+	}
+	if r := triangle.Radius(); !near(r, Vec2{X: 12, Y: 15}.Len()) {
+		t.Errorf("polygon: got %v", r)
+	}
+}
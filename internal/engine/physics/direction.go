@@ -1,10 +1,5 @@
 package physics
 
-import "math"
-
 func DirectionTo(p1, p2 *Position) float64 {
-	deltaX := p2.X - p1.X
-	deltaY := p2.Y - p1.Y
-
-	return math.Atan2(deltaY, deltaX)
+	return p2.Sub(p1.Vec2).Angle()
 }
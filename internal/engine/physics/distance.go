@@ -1,7 +1,5 @@
 package physics
 
-import "math"
-
 func Distance(p1, p2 *Position) float64 {
-	return math.Sqrt(math.Pow(p1.X-p2.X, 2) + math.Pow(p1.Y-p2.Y, 2))
+	return p1.Sub(p2.Vec2).Len()
 }
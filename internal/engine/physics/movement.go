@@ -7,12 +7,7 @@ func Accelerate(v, vmax, a, dt float64) float64 {
 	return math.Min(vmax, v+a*dt)
 }
 
-// Move computes new x and y coordinates based on direction, speed and passed time
-func Move(x, y, direction, v, dt float64) (float64, float64) {
-	return x + dt*v*math.Cos(direction), y + dt*v*math.Sin(direction)
-}
-
-func Move2(p *Position, v, dt float64) {
-	p.X += dt * v * math.Cos(p.Direction)
-	p.Y += dt * v * math.Sin(p.Direction)
+// Move computes the new location based on direction, speed and passed time
+func Move(p Vec2, direction, v, dt float64) Vec2 {
+	return p.Add(FromAngle(direction).Scale(dt * v))
 }
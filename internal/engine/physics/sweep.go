@@ -0,0 +1,71 @@
+package physics
+
+import "math"
+
+// Sweep tests the circle a, moving in a straight line from `from` to `to` during the tick, against
+// b at pb. It returns the earliest fraction of the movement at which the shapes touch.
+func Sweep(a Circle, from, to Vec2, b Shape, pb *Position) (float64, bool) {
+	r := a.R
+	if Collides(a, &Position{Vec2: from}, b, pb) {
+		return 0, true
+	}
+	d := to.Sub(from)
+	if d == (Vec2{}) {
+		return 0, false
+	}
+
+	// Moving the circle against b is a ray against b grown by the radius of the circle.
+	sb := b.place(pb)
+	hit := math.Inf(1)
+	if sb.points == nil {
+		if t, ok := rayCircle(from, d, sb.center, r+sb.radius); ok {
+			hit = t
+		}
+	}
+	for i, p := range sb.points {
+		q := sb.points[(i+1)%len(sb.points)]
+		edge := q.Sub(p)
+		normal := Vec2{X: edge.Y, Y: -edge.X}.Normalize()
+		if normal.Dot(p.Add(q).Scale(0.5).Sub(sb.center)) < 0 {
+			normal = normal.Scale(-1)
+		}
+		offset := normal.Scale(r)
+		if t, ok := raySegment(from, d, p.Add(offset), q.Add(offset)); ok {
+			hit = math.Min(hit, t)
+		}
+		if t, ok := rayCircle(from, d, p, r); ok {
+			hit = math.Min(hit, t)
+		}
+	}
+	if hit > 1 {
+		return 0, false
+	}
+	return hit, true
+}
+
+// rayCircle returns the first t in [0, 1] at which o + t*d enters the circle around c. Rays that
+// only touch the circle miss it, like touching shapes don't collide.
+func rayCircle(o, d, c Vec2, r float64) (float64, bool) {
+	f := o.Sub(c)
+	a := d.Dot(d)
+	b := 2 * f.Dot(d)
+	disc := b*b - 4*a*(f.Dot(f)-r*r)
+	if a == 0 || disc <= 0 {
+		return 0, false
+	}
+	t := (-b - math.Sqrt(disc)) / (2 * a)
+	return t, t >= 0 && t <= 1
+}
+
+// raySegment returns the t in [0, 1] at which o + t*d crosses the segment from p to q.
+func raySegment(o, d, p, q Vec2) (float64, bool) {
+	e := q.Sub(p)
+	denom := d.Cross(e)
+	if denom == 0 {
+		return 0, false
+	}
+	w := p.Sub(o)
+	t := w.Cross(e) / denom
+	u := w.Cross(d) / denom
+	return t, t >= 0 && t <= 1 && u >= 0 && u <= 1
+}
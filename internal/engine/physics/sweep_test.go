@@ -0,0 +1,99 @@
+package physics
+
+import "testing"
+
+func TestSweep(t *testing.T) {
+	bullet := Circle{R: 5}
+	box := Rectangle{W: 30, H: 30}
+	at := NewPosition(100, 0, 0)
+	tests := []struct {
+		name     string
+		from, to Vec2
+		hit      bool
+		time     float64
+	}{
+		// The bullet touches the box when its center is 5 in front of the edge at x=85.
+		{"crosses the box", Vec2{X: 0}, Vec2{X: 200}, true, 0.4},
+		{"ends inside", Vec2{X: 0}, Vec2{X: 100}, true, 0.8},
+		{"stops short", Vec2{X: 0}, Vec2{X: 79}, false, 0},
+		{"passes above", Vec2{X: 0, Y: -21}, Vec2{X: 200, Y: -21}, false, 0},
+		{"grazes the corner", Vec2{X: 0, Y: -19}, Vec2{X: 200, Y: -19}, true, 0.41},
+		{"moves away", Vec2{X: 79}, Vec2{X: 0}, false, 0},
+		{"starts inside", Vec2{X: 100}, Vec2{X: 300}, true, 0},
+		{"starts overlapping", Vec2{X: 82}, Vec2{X: 0}, true, 0},
+		{"doesn't move", Vec2{X: 50}, Vec2{X: 50}, false, 0},
+	}
+	for _, tt := range tests {
+		time, hit := Sweep(bullet, tt.from, tt.to, box, at)
+		if hit != tt.hit || !near(time, tt.time) {
+			t.Errorf("%s: got %v at %v, want %v at %v", tt.name, hit, time, tt.hit, tt.time)
+		}
+	}
+}
+
+func TestSweepRoundCorners(t *testing.T) {
+	// Diagonally past the corner at (115, -15): the grown box has a round corner there.
+	at := NewPosition(100, 0, 0)
+	from := Vec2{X: 130, Y: -40}
+	if _, hit := Sweep(Circle{R: 5}, from, from.Add(Vec2{X: -10, Y: 10}.Scale(1.1)), Rectangle{W: 30, H: 30}, at); hit {
+		t.Error("expected to miss the corner")
+	}
+	time, hit := Sweep(Circle{R: 5}, from, Vec2{X: 115, Y: -15}, Rectangle{W: 30, H: 30}, at)
+	want := 1 - 5/Vec2{X: 15, Y: 25}.Len()
+	if !hit || !near(time, want) {
+		t.Errorf("got %v at %v, want a hit at %v", hit, time, want)
+	}
+}
+
+func TestSweepCircles(t *testing.T) {
+	time, hit := Sweep(Circle{R: 5}, Vec2{X: 0}, Vec2{X: 100}, Circle{R: 15}, NewPosition(50, 0, 0))
+	if !hit || !near(time, 0.3) {
+		t.Errorf("got %v at %v, want a hit at 0.3", hit, time)
+	}
+	if _, hit := Sweep(Circle{R: 5}, Vec2{X: 0, Y: 20}, Vec2{X: 100, Y: 20}, Circle{R: 15}, NewPosition(50, 0, 0)); hit {
+		t.Error("expected touching circles not to collide")
+	}
+}
+
+func TestRayCircle(t *testing.T) {
+	tests := []struct {
+		o, d, c Vec2
+		r       float64
+		hit     bool
+		time    float64
+	}{
+		{Vec2{}, Vec2{X: 10}, Vec2{X: 5}, 1, true, 0.4},
+		{Vec2{}, Vec2{X: 10}, Vec2{X: 5, Y: 2}, 1, false, 0},
+		{Vec2{}, Vec2{X: 2}, Vec2{X: 5}, 1, false, 0},
+		{Vec2{}, Vec2{X: -10}, Vec2{X: 5}, 1, false, 0},
+		{Vec2{}, Vec2{}, Vec2{X: 5}, 1, false, 0},
+	}
+	for _, tt := range tests {
+		time, hit := rayCircle(tt.o, tt.d, tt.c, tt.r)
+		if hit != tt.hit || (hit && !near(time, tt.time)) {
+			t.Errorf("rayCircle(%v, %v, %v, %v): got %v at %v, want %v at %v", tt.o, tt.d, tt.c, tt.r, hit, time, tt.hit, tt.time)
+		}
+	}
+}
+
+func TestRaySegment(t *testing.T) {
+	p, q := Vec2{X: 5, Y: -1}, Vec2{X: 5, Y: 1}
+	tests := []struct {
+		o, d Vec2
+		hit  bool
+		time float64
+	}{
+		{Vec2{}, Vec2{X: 10}, true, 0.5},
+		{Vec2{Y: 1}, Vec2{X: 10}, true, 0.5},
+		{Vec2{Y: 2}, Vec2{X: 10}, false, 0},
+		{Vec2{}, Vec2{X: 4}, false, 0},
+		{Vec2{}, Vec2{X: -10}, false, 0},
+		{Vec2{}, Vec2{Y: 10}, false, 0},
+	}
+	for _, tt := range tests {
+		time, hit := raySegment(tt.o, tt.d, p, q)
+		if hit != tt.hit || (hit && !near(time, tt.time)) {
+			t.Errorf("raySegment(%v, %v): got %v at %v, want %v at %v", tt.o, tt.d, hit, time, tt.hit, tt.time)
+		}
+	}
+}
@@ -1,7 +1,13 @@
 package physics
 
+// Position is the center of an entity and the direction it faces.
 type Position struct {
-	X, Y, Direction float64
+	Vec2
+	Direction float64
+}
+
+func NewPosition(x, y, direction float64) *Position {
+	return &Position{Vec2: Vec2{X: x, Y: y}, Direction: direction}
 }
 
 type Rectangle struct {
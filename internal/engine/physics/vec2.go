@@ -0,0 +1,70 @@
+package physics
+
+import "math"
+
+// Vec2 is a 2D vector. Angles are measured from the positive x axis towards the positive y axis.
+type Vec2 struct {
+	X, Y float64
+}
+
+// FromAngle returns the unit vector pointing in direction a.
+func FromAngle(a float64) Vec2 {
+	sin, cos := math.Sincos(a)
+	return Vec2{X: cos, Y: sin}
+}
+
+func (v Vec2) Add(o Vec2) Vec2 {
+	return Vec2{X: v.X + o.X, Y: v.Y + o.Y}
+}
+
+func (v Vec2) Sub(o Vec2) Vec2 {
+	return Vec2{X: v.X - o.X, Y: v.Y - o.Y}
+}
+
+func (v Vec2) Scale(f float64) Vec2 {
+	return Vec2{X: v.X * f, Y: v.Y * f}
+}
+
+func (v Vec2) Dot(o Vec2) float64 {
+	return v.X*o.X + v.Y*o.Y
+}
+
+// Cross returns the z component of the cross product. It is positive if o is rotated
+// by less than Deg180 in positive direction from v.
+func (v Vec2) Cross(o Vec2) float64 {
+	return v.X*o.Y - v.Y*o.X
+}
+
+func (v Vec2) Len() float64 {
+	return math.Sqrt(v.Dot(v))
+}
+
+// Normalize returns the unit vector in the direction of v. The zero vector stays zero.
+func (v Vec2) Normalize() Vec2 {
+	l := v.Len()
+	if l == 0 {
+		return v
+	}
+	return v.Scale(1 / l)
+}
+
+// Rotate rotates v by a.
+func (v Vec2) Rotate(a float64) Vec2 {
+	sin, cos := math.Sincos(a)
+	return Vec2{X: v.X*cos - v.Y*sin, Y: v.X*sin + v.Y*cos}
+}
+
+// Lerp interpolates between v at t=0 and o at t=1.
+func (v Vec2) Lerp(o Vec2, t float64) Vec2 {
+	return v.Add(o.Sub(v).Scale(t))
+}
+
+// Angle returns the direction of v.
+func (v Vec2) Angle() float64 {
+	return math.Atan2(v.Y, v.X)
+}
+
+// AngleTo returns the signed angle to rotate v by to point in the direction of o. It is within [-Deg180, Deg180].
+func (v Vec2) AngleTo(o Vec2) float64 {
+	return math.Atan2(v.Cross(o), v.Dot(o))
+}
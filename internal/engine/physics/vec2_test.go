@@ -0,0 +1,170 @@
+package physics
+
+import (
+	"math"
+	"testing"
+)
+
+const epsilon = 1e-9
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < epsilon
+}
+
+func nearVec(a, b Vec2) bool {
+	return near(a.X, b.X) && near(a.Y, b.Y)
+}
+
+func TestVec2Arithmetic(t *testing.T) {
+	tests := []struct {
+		name string
+		got  Vec2
+		want Vec2
+	}{
+		{"add", Vec2{1, 2}.Add(Vec2{3, -4}), Vec2{4, -2}},
+		{"add zero", Vec2{1, 2}.Add(Vec2{}), Vec2{1, 2}},
+		{"sub", Vec2{1, 2}.Sub(Vec2{3, -4}), Vec2{-2, 6}},
+		{"sub self", Vec2{1, 2}.Sub(Vec2{1, 2}), Vec2{}},
+		{"scale", Vec2{1, -2}.Scale(3), Vec2{3, -6}},
+		{"scale by zero", Vec2{1, -2}.Scale(0), Vec2{}},
+		{"scale negative", Vec2{1, -2}.Scale(-0.5), Vec2{-0.5, 1}},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestVec2Products(t *testing.T) {
+	tests := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"dot", Vec2{1, 2}.Dot(Vec2{3, 4}), 11},
+		{"dot orthogonal", Vec2{1, 0}.Dot(Vec2{0, 5}), 0},
+		{"dot zero", Vec2{}.Dot(Vec2{3, 4}), 0},
+		{"cross positive", Vec2{1, 0}.Cross(Vec2{0, 1}), 1},
+		{"cross negative", Vec2{0, 1}.Cross(Vec2{1, 0}), -1},
+		{"cross parallel", Vec2{1, 2}.Cross(Vec2{2, 4}), 0},
+		{"len", Vec2{3, 4}.Len(), 5},
+		{"len negative", Vec2{-3, -4}.Len(), 5},
+		{"len zero", Vec2{}.Len(), 0},
+	}
+	for _, tt := range tests {
+		if !near(tt.got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestVec2Normalize(t *testing.T) {
+	tests := []struct {
+		v    Vec2
+		want Vec2
+	}{
+		{Vec2{3, 4}, Vec2{0.6, 0.8}},
+		{Vec2{0, -2}, Vec2{0, -1}},
+		{Vec2{1e-6, 0}, Vec2{1, 0}},
+		{Vec2{}, Vec2{}},
+	}
+	for _, tt := range tests {
+		if got := tt.v.Normalize(); !nearVec(got, tt.want) {
+			t.Errorf("%v.Normalize(): got %v, want %v", tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestVec2Rotate(t *testing.T) {
+	tests := []struct {
+		v    Vec2
+		a    float64
+		want Vec2
+	}{
+		{Vec2{1, 0}, Deg90, Vec2{0, 1}},
+		{Vec2{1, 0}, -Deg90, Vec2{0, -1}},
+		{Vec2{1, 2}, Deg180, Vec2{-1, -2}},
+		{Vec2{1, 2}, Deg360, Vec2{1, 2}},
+		{Vec2{1, 0}, Deg45, Vec2{math.Sqrt2 / 2, math.Sqrt2 / 2}},
+		{Vec2{}, Deg90, Vec2{}},
+	}
+	for _, tt := range tests {
+		if got := tt.v.Rotate(tt.a); !nearVec(got, tt.want) {
+			t.Errorf("%v.Rotate(%v): got %v, want %v", tt.v, tt.a, got, tt.want)
+		}
+	}
+}
+
+func TestVec2Lerp(t *testing.T) {
+	a, b := Vec2{0, 10}, Vec2{10, -10}
+	tests := []struct {
+		t    float64
+		want Vec2
+	}{
+		{0, a},
+		{1, b},
+		{0.5, Vec2{5, 0}},
+		{2, Vec2{20, -30}},
+	}
+	for _, tt := range tests {
+		if got := a.Lerp(b, tt.t); !nearVec(got, tt.want) {
+			t.Errorf("Lerp(%v): got %v, want %v", tt.t, got, tt.want)
+		}
+	}
+}
+
+func TestVec2Angles(t *testing.T) {
+	tests := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"angle x", Vec2{1, 0}.Angle(), Deg0},
+		{"angle y", Vec2{0, 2}.Angle(), Deg90},
+		{"angle -x", Vec2{-1, 0}.Angle(), Deg180},
+		{"angle -y", Vec2{0, -1}.Angle(), -Deg90},
+		{"angle zero", Vec2{}.Angle(), 0},
+		{"angle to positive", Vec2{1, 0}.AngleTo(Vec2{0, 3}), Deg90},
+		{"angle to negative", Vec2{1, 0}.AngleTo(Vec2{1, -1}), -Deg45},
+		{"angle to same", Vec2{1, 1}.AngleTo(Vec2{2, 2}), 0},
+		{"angle to opposite", Vec2{1, 0}.AngleTo(Vec2{-1, 0}), Deg180},
+		{"angle to wraps", Vec2{-1, 1}.AngleTo(Vec2{-1, -1}), Deg90},
+	}
+	for _, tt := range tests {
+		if !near(tt.got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestFromAngle(t *testing.T) {
+	for _, a := range []float64{Deg0, Deg45, Deg90, Deg135, Deg180, Deg270, -Deg45} {
+		v := FromAngle(a)
+		if !near(v.Len(), 1) {
+			t.Errorf("FromAngle(%v): got %v, want a unit vector", a, v)
+		}
+		if !nearVec(v, Vec2{1, 0}.Rotate(a)) {
+			t.Errorf("FromAngle(%v) and Rotate disagree: %v", a, v)
+		}
+	}
+}
+
+func TestMove(t *testing.T) {
+	if v := Accelerate(10, 15, 4, 2); v != 15 {
+		t.Errorf("got velocity %v, want it capped at 15", v)
+	}
+	if v := Accelerate(10, 15, -4, 2); v != 2 {
+		t.Errorf("got velocity %v, want 2", v)
+	}
+	got := Move(Vec2{10, 10}, Deg90, 20, 0.5)
+	if want := (Vec2{10, 20}); !nearVec(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if d := Distance(NewPosition(0, 0, 0), NewPosition(3, 4, 0)); !near(d, 5) {
+		t.Errorf("got distance %v, want 5", d)
+	}
+	if a := DirectionTo(NewPosition(1, 1, 0), NewPosition(1, 5, 0)); !near(a, Deg90) {
+		t.Errorf("got direction %v, want %v", a, Deg90)
+	}
+}
@@ -0,0 +1,9 @@
+package engine
+
+import "cfichtmueller.com/htmx-game/internal/engine/physics"
+
+const PrefabSpeedPowerUp = "speedPowerUp"
+
+func SpawnSpeedPowerUp(world *World, x, y float64) Entity {
+	return world.mustSpawn(PrefabSpeedPowerUp, x, y, -physics.Deg90)
+}
@@ -0,0 +1,216 @@
+package engine
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"cfichtmueller.com/htmx-game/internal/engine/physics"
+)
+
+// Prefab describes the components of an entity. Components are keyed by the name of their store
+// and use the same format as in snapshots. The position is set on spawn.
+type Prefab struct {
+	Type       EntityType                 `json:"type"`
+	Components map[string]json.RawMessage `json:"components"`
+}
+
+//go:embed prefabs/*.json
+var defaultPrefabFiles embed.FS
+
+var defaultPrefabs map[string]*Prefab
+
+func init() {
+	fsys, err := fs.Sub(defaultPrefabFiles, "prefabs")
+	if err != nil {
+		panic(err)
+	}
+	defaultPrefabs, err = LoadPrefabs(fsys)
+	if err != nil {
+		panic(fmt.Errorf("unable to load default prefabs: %v", err))
+	}
+}
+
+// DefaultPrefabs returns the prefabs that every world starts with.
+func DefaultPrefabs() map[string]*Prefab {
+	prefabs := make(map[string]*Prefab, len(defaultPrefabs))
+	for name, prefab := range defaultPrefabs {
+		prefabs[name] = prefab
+	}
+	return prefabs
+}
+
+// LoadPrefabs reads all .json files in the root of fsys. Prefabs are named after their file.
+func LoadPrefabs(fsys fs.FS) (map[string]*Prefab, error) {
+	files, err := fs.Glob(fsys, "*.json")
+	if err != nil {
+		return nil, fmt.Errorf("unable to list prefabs: %v", err)
+	}
+	prefabs := make(map[string]*Prefab, len(files))
+	for _, file := range files {
+		b, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read prefab %s: %v", file, err)
+		}
+		var prefab Prefab
+		if err := json.Unmarshal(b, &prefab); err != nil {
+			return nil, fmt.Errorf("unable to decode prefab %s: %v", file, err)
+		}
+		prefabs[strings.TrimSuffix(path.Base(file), ".json")] = &prefab
+	}
+	return prefabs, nil
+}
+
+// AddPrefabs makes prefabs available to Spawn, replacing prefabs with the same name.
+// Nothing is added if one of the prefabs is invalid.
+func (w *World) AddPrefabs(prefabs map[string]*Prefab) error {
+	merged := make(map[string]*Prefab, len(w.prefabs)+len(prefabs))
+	for name, prefab := range w.prefabs {
+		merged[name] = prefab
+	}
+	for name, prefab := range prefabs {
+		merged[name] = prefab
+	}
+	for _, name := range sortedKeys(prefabs) {
+		if err := w.validatePrefab(prefabs[name], merged); err != nil {
+			return fmt.Errorf("invalid prefab %s: %v", name, err)
+		}
+	}
+	w.prefabs = merged
+	for name, prefab := range prefabs {
+		w.prefabFields[name] = fieldsOf(prefab)
+	}
+	return nil
+}
+
+// fieldsOf returns the fields that the prefab sets, e.g. "healths.ttl".
+func fieldsOf(prefab *Prefab) map[string]bool {
+	fields := make(map[string]bool)
+	for name, data := range prefab.Components {
+		var component map[string]json.RawMessage
+		json.Unmarshal(data, &component)
+		for field := range component {
+			// encoding/json matches keys regardless of case.
+			fields[strings.ToLower(name+"."+field)] = true
+		}
+	}
+	return fields
+}
+
+func (w *World) validatePrefab(prefab *Prefab, prefabs map[string]*Prefab) error {
+	for _, name := range sortedKeys(prefab.Components) {
+		data := prefab.Components[name]
+		store, ok := w.Components.byName[name]
+		if !ok {
+			return fmt.Errorf("unknown component store %s", name)
+		}
+		if store == w.Components.Positions || store == w.Components.EntityTypes {
+			return fmt.Errorf("%s are set on spawn", name)
+		}
+		if store == w.Components.Parents {
+			return fmt.Errorf("%s are set with Attach", name)
+		}
+		if err := store.validate(data); err != nil {
+			return fmt.Errorf("unable to decode %s: %v", name, err)
+		}
+		switch store {
+		case w.Components.Behaviors:
+			var behavior Behavior
+			json.Unmarshal(data, &behavior)
+			if _, ok := behaviorFactories[behavior.Name]; !ok {
+				return fmt.Errorf("unknown behavior %s", behavior.Name)
+			}
+			for _, required := range behaviorRequirements[behavior.Name] {
+				if _, ok := prefab.Components[required]; !ok {
+					return fmt.Errorf("behavior %s needs %s", behavior.Name, required)
+				}
+			}
+		case w.Components.Colliders:
+			var collider Collider
+			json.Unmarshal(data, &collider)
+			if (collider.Circle == nil) == (collider.Polygon == nil) {
+				return fmt.Errorf("colliders need either a circle or a polygon")
+			}
+			if collider.Circle != nil && collider.Circle.R <= 0 {
+				return fmt.Errorf("collider circle needs a positive radius")
+			}
+			if collider.Polygon != nil && !collider.Polygon.Convex() {
+				return fmt.Errorf("collider polygon must be convex")
+			}
+		case w.Components.RigidBodies:
+			var body RigidBody
+			json.Unmarshal(data, &body)
+			if !body.Static && body.Mass <= 0 {
+				return fmt.Errorf("dynamic rigid bodies need a positive mass")
+			}
+			if body.Restitution < 0 || body.Restitution > 1 {
+				return fmt.Errorf("rigid body restitution must be between 0 and 1")
+			}
+		case w.Components.Sweeps:
+			var collider Collider
+			json.Unmarshal(prefab.Components["colliders"], &collider)
+			if collider.Circle == nil {
+				return fmt.Errorf("sweeps need a circle collider")
+			}
+		case w.Components.Spawners:
+			var spawner Spawner
+			json.Unmarshal(data, &spawner)
+			if _, ok := prefabs[spawner.Prefab]; !ok {
+				return fmt.Errorf("unknown spawner prefab %s", spawner.Prefab)
+			}
+			if spawner.IntervalMin < 0 || spawner.IntervalMax < spawner.IntervalMin {
+				return fmt.Errorf("invalid spawner interval")
+			}
+		}
+	}
+	return nil
+}
+
+// Spawn adds an entity built from the named prefab, centered on the given position.
+func (w *World) Spawn(prefab string, x, y, direction float64) (Entity, error) {
+	p, ok := w.prefabs[prefab]
+	if !ok {
+		return NoEntity, fmt.Errorf("unknown prefab %s", prefab)
+	}
+	entity := w.AddEntity(p.Type)
+	for _, name := range sortedKeys(p.Components) {
+		if err := w.Components.byName[name].decode(entity, p.Components[name]); err != nil {
+			w.RemoveEntity(entity)
+			return NoEntity, fmt.Errorf("unable to decode %s of prefab %s: %v", name, prefab, err)
+		}
+	}
+	w.Components.Positions.Add(entity, physics.NewPosition(x, y, direction))
+	if sweep, ok := w.Components.Sweeps.Get(entity); ok {
+		sweep.From = physics.Vec2{X: x, Y: y}
+	}
+	w.tune(prefab, entity)
+	if velocity, ok := w.Components.Velocities.Get(entity); ok {
+		velocity.Turn(direction)
+	}
+	if behavior, ok := w.Components.Behaviors.Get(entity); ok {
+		behavior.Tree = behaviorFactories[behavior.Name](w, entity)
+	}
+	return entity, nil
+}
+
+// mustSpawn spawns one of the built-in prefabs. These are validated when they are added.
+func (w *World) mustSpawn(prefab string, x, y, direction float64) Entity {
+	entity, err := w.Spawn(prefab, x, y, direction)
+	if err != nil {
+		panic(err)
+	}
+	return entity
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,48 @@
+package engine
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// testPrefabs returns a tank prefab with the given components.
+func testPrefabs(t *testing.T, name, components string) map[string]*Prefab {
+	t.Helper()
+	var c map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(components), &c); err != nil {
+		t.Fatal(err)
+	}
+	return map[string]*Prefab{name: {Type: Tank, Components: c}}
+}
+
+func TestSweepsNeedCircleCollider(t *testing.T) {
+	w := NewWorld(1000, 600, 1)
+	if err := w.AddPrefabs(testPrefabs(t, "fastBullet", `{"boundingBoxes": {"w": 10, "h": 10}, "sweeps": {}}`)); err == nil {
+		t.Error("expected a swept box to be rejected")
+	}
+	if err := w.AddPrefabs(testPrefabs(t, "fastBullet", `{"boundingBoxes": {"w": 10, "h": 10}, "colliders": {"circle": {"r": 5}}, "sweeps": {}}`)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBehaviorsNeedComponents(t *testing.T) {
+	tests := []struct {
+		components string
+		valid      bool
+	}{
+		{`{"behaviors": {"name": "tank"}}`, false},
+		{`{"behaviors": {"name": "tank"}, "autoMove": {}}`, false},
+		{`{"behaviors": {"name": "tank"}, "autoMove": {}, "sensings": {}}`, true},
+		{`{"behaviors": {"name": "tower"}}`, false},
+		{`{"behaviors": {"name": "tower"}, "autoMove": {}}`, true},
+		{`{"behaviors": {"name": "tankShelter"}}`, false},
+		{`{"behaviors": {"name": "tankShelter"}, "spawners": {"prefab": "tank"}}`, true},
+	}
+	for _, tt := range tests {
+		w := NewWorld(1000, 600, 1)
+		err := w.AddPrefabs(testPrefabs(t, "custom", tt.components))
+		if (err == nil) != tt.valid {
+			t.Errorf("%s: got %v, want valid %v", tt.components, err, tt.valid)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package engine
+
+import (
+	"sort"
+	"time"
+)
+
+// profileSamples is the number of ticks the rolling statistics cover.
+const profileSamples = 300
+
+// rolling keeps the most recent samples of a value.
+type rolling struct {
+	samples []int64
+	next    int
+}
+
+func newRolling() *rolling {
+	return &rolling{samples: make([]int64, 0, profileSamples)}
+}
+
+func (r *rolling) add(v int64) {
+	if len(r.samples) < cap(r.samples) {
+		r.samples = append(r.samples, v)
+		return
+	}
+	r.samples[r.next] = v
+	r.next = (r.next + 1) % len(r.samples)
+}
+
+// percentiles returns the values below which the fractions ps of samples fall.
+func (r *rolling) percentiles(ps ...float64) []int64 {
+	result := make([]int64, len(ps))
+	if len(r.samples) == 0 {
+		return result
+	}
+	sorted := make([]int64, len(r.samples))
+	copy(sorted, r.samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	for i, p := range ps {
+		result[i] = sorted[int(p*float64(len(sorted)-1))]
+	}
+	return result
+}
+
+func (r *rolling) durations() Percentiles[time.Duration] {
+	p := r.percentiles(0.5, 0.99)
+	return Percentiles[time.Duration]{P50: time.Duration(p[0]), P99: time.Duration(p[1])}
+}
+
+func (r *rolling) counts() Percentiles[int] {
+	p := r.percentiles(0.5, 0.99)
+	return Percentiles[int]{P50: int(p[0]), P99: int(p[1])}
+}
+
+type Percentiles[T any] struct {
+	P50 T
+	P99 T
+}
+
+type SystemStats struct {
+	Name   string
+	Phase  Phase
+	Update Percentiles[time.Duration]
+}
+
+type WorldStats struct {
+	Update     Percentiles[time.Duration]
+	Entities   Percentiles[int]
+	Collisions Percentiles[int]
+	Systems    []SystemStats
+}
+
+type worldProfile struct {
+	update     *rolling
+	entities   *rolling
+	collisions *rolling
+	systems    map[System]*rolling
+}
+
+func newWorldProfile() *worldProfile {
+	return &worldProfile{
+		update:     newRolling(),
+		entities:   newRolling(),
+		collisions: newRolling(),
+		systems:    make(map[System]*rolling),
+	}
+}
+
+func (p *worldProfile) system(s System) *rolling {
+	r, ok := p.systems[s]
+	if !ok {
+		r = newRolling()
+		p.systems[s] = r
+	}
+	return r
+}
+
+// Stats returns rolling statistics over the most recent updates. Systems are listed in the order they run.
+func (w *World) Stats() WorldStats {
+	stats := WorldStats{
+		Update:     w.profile.update.durations(),
+		Entities:   w.profile.entities.counts(),
+		Collisions: w.profile.collisions.counts(),
+		Systems:    make([]SystemStats, 0, len(w.systems)),
+	}
+	schedules := make(map[System]Schedule, len(w.systems))
+	for _, s := range w.systems {
+		schedules[s.system] = s.schedule
+	}
+	for _, system := range w.order {
+		stats.Systems = append(stats.Systems, SystemStats{
+			Name:   schedules[system].Name,
+			Phase:  schedules[system].Phase,
+			Update: w.profile.system(system).durations(),
+		})
+	}
+	return stats
+}
+
+type EngineStats struct {
+	World     WorldStats
+	TickRate  int
+	Fps       int
+	Paused    bool
+	TimeScale float64
+	// LockHeld is how long the engine lock was held by anyone, including the simulation loop.
+	LockHeld Percentiles[time.Duration]
+	// TickLockWait is how long the simulation loop waited for the lock held by others.
+	TickLockWait Percentiles[time.Duration]
+	// InputDelay is how long player commands waited in the queue before they were applied.
+	InputDelay Percentiles[time.Duration]
+}
+
+// Stats returns the current statistics. The caller must hold the lock.
+func (e *Engine) Stats() EngineStats {
+	return EngineStats{
+		World:        e.World.Stats(),
+		TickRate:     e.tickRate,
+		Fps:          e.Fps,
+		Paused:       e.paused,
+		TimeScale:    e.timeScale,
+		LockHeld:     e.lockHeld.durations(),
+		TickLockWait: e.tickLockWait.durations(),
+		InputDelay:   e.inputDelay.durations(),
+	}
+}
@@ -0,0 +1,199 @@
+package engine
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Term restricts a query to entities with or without a component.
+type Term struct {
+	store   store
+	without bool
+}
+
+// TermFunc creates a term for a component storage. With and Without are term funcs.
+type TermFunc func(s *ComponentStorage) Term
+
+// With matches entities that have a component of type T. It panics if there is no store for T.
+func With[T any](s *ComponentStorage) Term {
+	return Term{store: mustStoreFor[T](s)}
+}
+
+// Without matches entities that don't have a component of type T. It panics if there is no store for T.
+func Without[T any](s *ComponentStorage) Term {
+	return Term{store: mustStoreFor[T](s), without: true}
+}
+
+func mustStoreFor[T any](s *ComponentStorage) store {
+	store, ok := StoreFor[T](s)
+	if !ok {
+		panic(fmt.Errorf("no store for components of type %v", reflect.TypeFor[T]()))
+	}
+	return store
+}
+
+// Query is a cached set of entities that match all of its terms. It is kept up to date
+// as components are added and removed. Entities are ordered by their index.
+type Query struct {
+	with     []store
+	without  []store
+	entities []Entity
+	shared   bool
+}
+
+// Entities returns the matching entities. The slice isn't modified by later changes to the query,
+// so systems may add and remove components while they range over it. Callers must not modify it.
+func (q *Query) Entities() []Entity {
+	q.shared = true
+	return q.entities
+}
+
+func (q *Query) Len() int {
+	return len(q.entities)
+}
+
+func (q *Query) matches(entity Entity) bool {
+	for _, s := range q.with {
+		if !s.Has(entity) {
+			return false
+		}
+	}
+	for _, s := range q.without {
+		if s.Has(entity) {
+			return false
+		}
+	}
+	return true
+}
+
+func (q *Query) search(entity Entity) (int, bool) {
+	i := sort.Search(len(q.entities), func(i int) bool {
+		return q.entities[i].Index() >= entity.Index()
+	})
+	return i, i < len(q.entities) && q.entities[i] == entity
+}
+
+// update adds or removes the entity after one of its components changed.
+func (q *Query) update(entity Entity) {
+	i, found := q.search(entity)
+	matches := q.matches(entity)
+	if matches == found {
+		return
+	}
+	if q.shared {
+		entities := make([]Entity, len(q.entities), len(q.entities)+1)
+		copy(entities, q.entities)
+		q.entities = entities
+		q.shared = false
+	}
+	if matches {
+		q.entities = append(q.entities, 0)
+		copy(q.entities[i+1:], q.entities[i:])
+		q.entities[i] = entity
+	} else {
+		q.entities = append(q.entities[:i], q.entities[i+1:]...)
+	}
+}
+
+// Query returns the entities that match all terms, e.g. Query(With[Velocity], Without[Health]).
+// Queries are cached, asking for the same terms again returns the same query.
+func (s *ComponentStorage) Query(terms ...TermFunc) *Query {
+	q := &Query{
+		with:     make([]store, 0, len(terms)),
+		without:  make([]store, 0),
+		entities: make([]Entity, 0),
+	}
+	key := strings.Builder{}
+	for _, f := range terms {
+		t := f(s)
+		if t.without {
+			q.without = append(q.without, t.store)
+			key.WriteString("-")
+		} else {
+			q.with = append(q.with, t.store)
+			key.WriteString("+")
+		}
+		key.WriteString(t.store.Name())
+	}
+	if len(q.with) == 0 {
+		panic(fmt.Errorf("query needs at least one With term"))
+	}
+
+	if cached, ok := s.queries[key.String()]; ok {
+		return cached
+	}
+
+	smallest := q.with[0]
+	for _, store := range q.with[1:] {
+		if store.Len() < smallest.Len() {
+			smallest = store
+		}
+	}
+	smallest.EachEntity(func(entity Entity) {
+		if q.matches(entity) {
+			q.entities = append(q.entities, entity)
+		}
+	})
+	sort.Slice(q.entities, func(i, j int) bool {
+		return q.entities[i].Index() < q.entities[j].Index()
+	})
+
+	s.queries[key.String()] = q
+	for _, store := range q.with {
+		s.queriesByStore[store] = append(s.queriesByStore[store], q)
+	}
+	for _, store := range q.without {
+		s.queriesByStore[store] = append(s.queriesByStore[store], q)
+	}
+	return q
+}
+
+func (s *ComponentStorage) componentChanged(store store, entity Entity) {
+	for _, q := range s.queriesByStore[store] {
+		q.update(entity)
+	}
+}
+
+// Query returns the entities that match all terms. See ComponentStorage.Query.
+func (w *World) Query(terms ...TermFunc) *Query {
+	return w.Components.Query(terms...)
+}
+
+// Each calls f for every entity with a component of type A that matches the other terms,
+// together with the component, e.g. Each(s, f, Without[Health]).
+func Each[A any](s *ComponentStorage, f func(entity Entity, a *A), terms ...TermFunc) {
+	q := s.Query(append([]TermFunc{With[A]}, terms...)...)
+	storeA, _ := StoreFor[A](s)
+	for _, entity := range q.Entities() {
+		a, _ := storeA.Get(entity)
+		f(entity, a)
+	}
+}
+
+// Each2 is Each for entities with components of types A and B.
+func Each2[A, B any](s *ComponentStorage, f func(entity Entity, a *A, b *B), terms ...TermFunc) {
+	q := s.Query(append([]TermFunc{With[A], With[B]}, terms...)...)
+	storeA, _ := StoreFor[A](s)
+	storeB, _ := StoreFor[B](s)
+	for _, entity := range q.Entities() {
+		a, _ := storeA.Get(entity)
+		b, _ := storeB.Get(entity)
+		f(entity, a, b)
+	}
+}
+
+// Each3 is Each for entities with components of types A, B and C.
+func Each3[A, B, C any](s *ComponentStorage, f func(entity Entity, a *A, b *B, c *C), terms ...TermFunc) {
+	q := s.Query(append([]TermFunc{With[A], With[B], With[C]}, terms...)...)
+	storeA, _ := StoreFor[A](s)
+	storeB, _ := StoreFor[B](s)
+	storeC, _ := StoreFor[C](s)
+	for _, entity := range q.Entities() {
+		a, _ := storeA.Get(entity)
+		b, _ := storeB.Get(entity)
+		c, _ := storeC.Get(entity)
+		f(entity, a, b, c)
+	}
+}
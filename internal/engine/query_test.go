@@ -0,0 +1,132 @@
+package engine
+
+import (
+	"slices"
+	"testing"
+
+	"cfichtmueller.com/htmx-game/internal/engine/physics"
+)
+
+func TestQueryFollowsComponentChanges(t *testing.T) {
+	w := NewWorld(1000, 600, 1)
+	a, b, c := w.AddEntity(Tank), w.AddEntity(Tank), w.AddEntity(Tank)
+	for _, entity := range []Entity{c, a, b} {
+		w.Components.Positions.Add(entity, physics.NewPosition(0, 0, 0))
+		w.Components.Velocities.Add(entity, &Velocity{})
+	}
+	w.Components.Healths.Add(b, &Health{})
+
+	q := w.Query(With[physics.Position], With[Velocity], Without[Health])
+	if w.Query(With[physics.Position], With[Velocity], Without[Health]) != q {
+		t.Error("expected the same terms to return the cached query")
+	}
+	expect := func(want ...Entity) {
+		t.Helper()
+		if got := q.Entities(); !slices.Equal(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+	expect(a, c)
+
+	before := q.Entities()
+	w.Components.Velocities.Remove(a)
+	expect(c)
+	if !slices.Equal(before, []Entity{a, c}) {
+		t.Errorf("expected the returned slice to be unchanged, got %v", before)
+	}
+
+	w.Components.Velocities.Add(a, &Velocity{})
+	w.Components.Healths.Remove(b)
+	expect(a, b, c)
+
+	w.Components.Healths.Add(c, &Health{})
+	expect(a, b)
+
+	w.RemoveEntity(a)
+	w.cleanupEntities()
+	expect(b)
+
+	d := w.AddEntity(Tank)
+	if d.Index() != a.Index() {
+		t.Fatalf("expected the index of %d to be reused, got %d", a, d)
+	}
+	w.Components.Positions.Add(d, physics.NewPosition(0, 0, 0))
+	if q.Len() != 1 {
+		t.Errorf("expected %d to be missing a velocity, got %v", d, q.Entities())
+	}
+	w.Components.Velocities.Add(d, &Velocity{})
+	expect(d, b)
+}
+
+func TestEachPassesComponents(t *testing.T) {
+	w := NewWorld(1000, 600, 1)
+	for i := 0; i < 3; i++ {
+		entity := w.AddEntity(Tank)
+		w.Components.Positions.Add(entity, physics.NewPosition(float64(i), 0, 0))
+		w.Components.Velocities.Add(entity, &Velocity{Linear: physics.Vec2{X: float64(i)}})
+		if i == 1 {
+			w.Components.Healths.Add(entity, &Health{})
+		}
+	}
+
+	var got []float64
+	Each2(w.Components, func(entity Entity, pos *physics.Position, velocity *Velocity) {
+		if pos.X != velocity.Linear.X {
+			t.Errorf("components of different entities: %v and %v", pos, velocity)
+		}
+		got = append(got, pos.X)
+	}, Without[Health])
+	if !slices.Equal(got, []float64{0, 2}) {
+		t.Errorf("got %v, want [0 2]", got)
+	}
+}
+
+// benchmarkWorld has many entities of which few move, like a battle with many static towers.
+func benchmarkWorld() *World {
+	w := NewWorld(1000, 600, 1)
+	for i := 0; i < 5000; i++ {
+		entity := w.AddEntity(Tower)
+		w.Components.Positions.Add(entity, physics.NewPosition(0, 0, 0))
+		w.Components.Healths.Add(entity, &Health{})
+		if i%10 == 0 {
+			w.Components.Velocities.Add(entity, &Velocity{Linear: physics.Vec2{X: 1}})
+		}
+	}
+	return w
+}
+
+func BenchmarkMovementFullScan(b *testing.B) {
+	w := benchmarkWorld()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, entity := range w.Entities {
+			pos, hasPos := w.Components.Positions.Get(entity)
+			velocity, hasVelocity := w.Components.Velocities.Get(entity)
+			if !hasPos || !hasVelocity {
+				continue
+			}
+			pos.Vec2 = pos.Add(velocity.Linear.Scale(0.01))
+		}
+	}
+}
+
+func BenchmarkMovementCachedQuery(b *testing.B) {
+	w := benchmarkWorld()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Each2(w.Components, func(entity Entity, pos *physics.Position, velocity *Velocity) {
+			pos.Vec2 = pos.Add(velocity.Linear.Scale(0.01))
+		})
+	}
+}
+
+func BenchmarkQueryUpdate(b *testing.B) {
+	w := benchmarkWorld()
+	w.Query(With[physics.Position], With[Velocity])
+	entity := w.Entities[len(w.Entities)/2]
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w.Components.Velocities.Add(entity, &Velocity{})
+		w.Components.Velocities.Remove(entity)
+	}
+}
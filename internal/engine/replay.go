@@ -0,0 +1,224 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// RecordingVersion is incremented whenever the recording format changes incompatibly.
+const RecordingVersion = 4
+
+const (
+	CommandSetVelocity = "setVelocity"
+	CommandSetRotation = "setRotation"
+	CommandRespawn     = "respawn"
+	CommandJoin        = "join"
+	CommandLeave       = "leave"
+	CommandTimeScale   = "timeScale"
+	CommandConfig      = "config"
+)
+
+var (
+	ErrReplaying      = errors.New("engine is replaying a recording")
+	ErrUnknownCommand = errors.New("unknown command")
+)
+
+type Command struct {
+	M string  `json:"m"`
+	V float64 `json:"v"`
+}
+
+// Input is a command applied before the step with the given tick.
+type Input struct {
+	Tick   uint64  `json:"tick"`
+	Player string  `json:"player,omitempty"`
+	Config *Config `json:"config,omitempty"`
+	Command
+}
+
+// Recording holds the world as it was when the recording started and every input since then.
+type Recording struct {
+	Version   int                `json:"version"`
+	Seed      uint64             `json:"seed"`
+	Width     float64            `json:"width"`
+	Height    float64            `json:"height"`
+	TickRate  int                `json:"tickRate"`
+	TimeScale float64            `json:"timeScale"`
+	Config    Config             `json:"config"`
+	Prefabs   map[string]*Prefab `json:"prefabs,omitempty"`
+	Snapshot  json.RawMessage    `json:"snapshot"`
+	Ticks     uint64             `json:"ticks"`
+	Inputs    []Input            `json:"inputs"`
+	// start is the tick of the engine at which the recording started.
+	start uint64
+}
+
+func (e *Engine) startRecording() error {
+	var snapshot bytes.Buffer
+	if err := e.writeSnapshot(&snapshot); err != nil {
+		return fmt.Errorf("unable to start recording: %v", err)
+	}
+	e.recording = &Recording{
+		Version:   RecordingVersion,
+		Seed:      e.seed,
+		Width:     e.World.Width,
+		Height:    e.World.Height,
+		TickRate:  e.tickRate,
+		TimeScale: e.timeScale,
+		Config:    e.World.config,
+		Prefabs:   e.prefabs,
+		Snapshot:  snapshot.Bytes(),
+		Inputs:    make([]Input, 0),
+		start:     e.tick,
+	}
+	return nil
+}
+
+// rotateRecording starts a new recording from a snapshot once the current one covers the
+// recording window. Only the previous recording is kept, so memory stays bounded while
+// downloads still cover at least the window.
+func (e *Engine) rotateRecording() {
+	if e.recording == nil || e.tick-e.recording.start < uint64(e.recordingWindow/e.stepDuration()) {
+		return
+	}
+	previous := e.recording
+	if err := e.startRecording(); err != nil {
+		// Keep the current recording, the next step tries again.
+		return
+	}
+	e.prevRecording = previous
+}
+
+func (e *Engine) record(player string, cmd Command) {
+	e.recordInput(Input{Player: player, Command: cmd})
+}
+
+func (e *Engine) recordInput(input Input) {
+	if e.recording == nil {
+		return
+	}
+	input.Tick = e.tick - e.recording.start
+	e.recording.Inputs = append(e.recording.Inputs, input)
+}
+
+// WriteRecording writes everything that was recorded up to the current tick to w. It starts with
+// the previous recording if there is one.
+func (e *Engine) WriteRecording(w io.Writer) error {
+	e.Lock()
+	defer e.Unlock()
+	if e.recording == nil {
+		return fmt.Errorf("engine isn't recording")
+	}
+	recording := *e.recording
+	if previous := e.prevRecording; previous != nil {
+		recording = *previous
+		recording.Inputs = make([]Input, 0, len(previous.Inputs)+len(e.recording.Inputs))
+		recording.Inputs = append(recording.Inputs, previous.Inputs...)
+		for _, input := range e.recording.Inputs {
+			input.Tick += e.recording.start - previous.start
+			recording.Inputs = append(recording.Inputs, input)
+		}
+	}
+	recording.Ticks = e.tick - recording.start
+	if err := json.NewEncoder(w).Encode(recording); err != nil {
+		return fmt.Errorf("unable to encode recording: %v", err)
+	}
+	return nil
+}
+
+func ReadRecording(r io.Reader) (*Recording, error) {
+	var recording Recording
+	if err := json.NewDecoder(r).Decode(&recording); err != nil {
+		return nil, fmt.Errorf("unable to decode recording: %v", err)
+	}
+	if recording.Version != RecordingVersion {
+		return nil, fmt.Errorf("unsupported recording version %d, expected %d", recording.Version, RecordingVersion)
+	}
+	if recording.TickRate <= 0 || recording.TimeScale <= 0 || recording.TimeScale > MaxTimeScale {
+		return nil, fmt.Errorf("invalid recording settings")
+	}
+	if err := recording.Config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid recording config: %v", err)
+	}
+	return &recording, nil
+}
+
+// NewReplay builds an engine that replays the recording. It is paused once all recorded ticks are replayed.
+func NewReplay(recording *Recording, opts ...Option) (*Engine, error) {
+	opts = append(opts, WithTickRate(recording.TickRate), WithPrefabs(recording.Prefabs))
+	e := New(recording.Width, recording.Height, opts...)
+	e.replay = recording
+	if err := e.rewind(); err != nil {
+		return nil, err
+	}
+	if err := e.Init(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Replaying reports whether the engine replays a recording. The caller must hold the lock.
+func (e *Engine) Replaying() bool {
+	return e.replay != nil
+}
+
+// ReplayTicks returns the number of recorded ticks. The caller must hold the lock.
+func (e *Engine) ReplayTicks() uint64 {
+	if e.replay == nil {
+		return 0
+	}
+	return e.replay.Ticks
+}
+
+// Seek replays the recording up to tick. Seeking backwards replays from the start.
+// The caller must hold the lock.
+func (e *Engine) Seek(tick uint64) error {
+	if e.replay == nil {
+		return fmt.Errorf("engine isn't replaying")
+	}
+	tick = min(tick, e.replay.Ticks)
+	if tick < e.tick {
+		if err := e.rewind(); err != nil {
+			return err
+		}
+	}
+	for e.tick < tick {
+		e.step()
+	}
+	return nil
+}
+
+func (e *Engine) rewind() error {
+	// Behavior trees are rebuilt with the current config.
+	e.World.config = e.replay.Config
+	if err := e.readSnapshot(e.replay.Snapshot); err != nil {
+		return fmt.Errorf("unable to restore recorded world: %v", err)
+	}
+	e.tick = 0
+	e.replayNext = 0
+	e.timeScale = e.replay.TimeScale
+	return nil
+}
+
+// feedReplay applies the inputs recorded for the current tick. It pauses the engine and
+// returns false at the end of the recording.
+func (e *Engine) feedReplay() bool {
+	if e.tick >= e.replay.Ticks {
+		e.paused = true
+		return false
+	}
+	inputs := e.replay.Inputs
+	for e.replayNext < len(inputs) && inputs[e.replayNext].Tick <= e.tick {
+		input := inputs[e.replayNext]
+		if input.M == CommandConfig && input.Config != nil {
+			e.setConfig(*input.Config)
+		} else {
+			e.apply(input.Player, input.Command)
+		}
+		e.replayNext++
+	}
+	return true
+}
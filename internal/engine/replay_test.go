@@ -0,0 +1,59 @@
+package engine
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestRecordingWindow(t *testing.T) {
+	live := New(1000, 600, WithSeed(7), WithTickRate(10), WithRecordingWindow(2*time.Second))
+	if err := live.Init(); err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{live.SpawnPlayer(), live.SpawnPlayer()}
+	states := make(map[uint64]string)
+	for i := 0; i < 75; i++ {
+		id := ids[i%len(ids)]
+		live.Enqueue(id, Command{M: CommandSetVelocity, V: 1})
+		live.Enqueue(id, Command{M: CommandSetRotation, V: float64(i) / 10})
+		if i == 30 {
+			config := DefaultConfig()
+			config.Tower.AimInterval = Range{Min: 0.5, Max: 1}
+			if err := live.SetConfig(config); err != nil {
+				t.Fatal(err)
+			}
+		}
+		live.Step(1)
+		states[live.tick] = positions(live.World)
+	}
+
+	var b bytes.Buffer
+	if err := live.WriteRecording(&b); err != nil {
+		t.Fatal(err)
+	}
+	recording, err := ReadRecording(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// New recordings started at ticks 20, 40 and 60. The download starts at 40.
+	if recording.Ticks != 35 {
+		t.Fatalf("expected the recording to cover 35 ticks, got %d", recording.Ticks)
+	}
+	if len(live.recording.Inputs) >= len(recording.Inputs) {
+		t.Errorf("expected the download to include the previous recording")
+	}
+
+	replay, err := NewReplay(recording)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tick := range []uint64{35, 5, 20, 21, 1, 35} {
+		if err := replay.Seek(tick); err != nil {
+			t.Fatal(err)
+		}
+		if positions(replay.World) != states[40+tick] {
+			t.Fatalf("replay diverged at tick %d", tick)
+		}
+	}
+}
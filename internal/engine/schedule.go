@@ -0,0 +1,129 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Phase groups systems. Phases run in the order they are declared in.
+type Phase int
+
+const (
+	PhaseInput Phase = iota
+	PhaseAI
+	PhaseMovement
+	PhaseCollision
+	PhaseHealth
+	PhaseCleanup
+)
+
+func (p Phase) String() string {
+	switch p {
+	case PhaseInput:
+		return "input"
+	case PhaseAI:
+		return "ai"
+	case PhaseMovement:
+		return "movement"
+	case PhaseCollision:
+		return "collision"
+	case PhaseHealth:
+		return "health"
+	case PhaseCleanup:
+		return "cleanup"
+	}
+	return fmt.Sprintf("phase(%d)", int(p))
+}
+
+// Schedule tells the world when to run a system. Before and After name other systems
+// that must run after or before this one. Systems without constraints run in the order they were added.
+type Schedule struct {
+	Name   string
+	Phase  Phase
+	Before []string
+	After  []string
+}
+
+type scheduledSystem struct {
+	schedule Schedule
+	system   System
+}
+
+// schedule computes the order systems run in.
+func (w *World) schedule() error {
+	byName := make(map[string]int, len(w.systems))
+	for i, s := range w.systems {
+		if _, ok := byName[s.schedule.Name]; ok {
+			return fmt.Errorf("system %s is added twice", s.schedule.Name)
+		}
+		byName[s.schedule.Name] = i
+	}
+
+	successors := make([][]int, len(w.systems))
+	predecessors := make([]int, len(w.systems))
+	addEdge := func(from, to int) error {
+		if w.systems[from].schedule.Phase > w.systems[to].schedule.Phase {
+			return fmt.Errorf(
+				"system %s (%v) can't run before %s (%v)",
+				w.systems[from].schedule.Name,
+				w.systems[from].schedule.Phase,
+				w.systems[to].schedule.Name,
+				w.systems[to].schedule.Phase,
+			)
+		}
+		successors[from] = append(successors[from], to)
+		predecessors[to]++
+		return nil
+	}
+	for i, s := range w.systems {
+		for _, name := range s.schedule.Before {
+			j, ok := byName[name]
+			if !ok {
+				return fmt.Errorf("system %s runs before unknown system %s", s.schedule.Name, name)
+			}
+			if err := addEdge(i, j); err != nil {
+				return err
+			}
+		}
+		for _, name := range s.schedule.After {
+			j, ok := byName[name]
+			if !ok {
+				return fmt.Errorf("system %s runs after unknown system %s", s.schedule.Name, name)
+			}
+			if err := addEdge(j, i); err != nil {
+				return err
+			}
+		}
+	}
+
+	order := make([]System, 0, len(w.systems))
+	done := make([]bool, len(w.systems))
+	for len(order) < len(w.systems) {
+		next := -1
+		for i, s := range w.systems {
+			if done[i] || predecessors[i] > 0 {
+				continue
+			}
+			if next == -1 || s.schedule.Phase < w.systems[next].schedule.Phase {
+				next = i
+			}
+		}
+		if next == -1 {
+			cycle := make([]string, 0)
+			for i, s := range w.systems {
+				if !done[i] {
+					cycle = append(cycle, s.schedule.Name)
+				}
+			}
+			return fmt.Errorf("systems depend on each other in a cycle: %s", strings.Join(cycle, ", "))
+		}
+		done[next] = true
+		order = append(order, w.systems[next].system)
+		for _, j := range successors[next] {
+			predecessors[j]--
+		}
+	}
+
+	w.order = order
+	return nil
+}
@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+type namedSystem struct {
+	name string
+	ran  *[]string
+}
+
+func (s *namedSystem) Update(entities []Entity, components *ComponentStorage, dt float64) {
+	*s.ran = append(*s.ran, s.name)
+}
+
+func TestSchedule(t *testing.T) {
+	tests := []struct {
+		name      string
+		schedules []Schedule
+		order     []string
+		err       string
+	}{
+		{
+			name: "phases before insertion order",
+			schedules: []Schedule{
+				{Name: "health", Phase: PhaseHealth},
+				{Name: "input", Phase: PhaseInput},
+				{Name: "ai", Phase: PhaseAI},
+			},
+			order: []string{"input", "ai", "health"},
+		},
+		{
+			name: "constraints within a phase",
+			schedules: []Schedule{
+				{Name: "a", Phase: PhaseMovement, After: []string{"b"}},
+				{Name: "b", Phase: PhaseMovement},
+				{Name: "c", Phase: PhaseMovement, Before: []string{"b"}},
+			},
+			order: []string{"c", "b", "a"},
+		},
+		{
+			name: "constraint along the phases",
+			schedules: []Schedule{
+				{Name: "cleanup", Phase: PhaseCleanup, After: []string{"input"}},
+				{Name: "input", Phase: PhaseInput},
+			},
+			order: []string{"input", "cleanup"},
+		},
+		{
+			name: "cycle",
+			schedules: []Schedule{
+				{Name: "a", Phase: PhaseMovement, Before: []string{"b"}},
+				{Name: "b", Phase: PhaseMovement, Before: []string{"c"}},
+				{Name: "c", Phase: PhaseMovement, After: []string{"b"}, Before: []string{"a"}},
+			},
+			err: "cycle",
+		},
+		{
+			name: "before an earlier phase",
+			schedules: []Schedule{
+				{Name: "health", Phase: PhaseHealth, Before: []string{"ai"}},
+				{Name: "ai", Phase: PhaseAI},
+			},
+			err: "can't run before",
+		},
+		{
+			name: "after a later phase",
+			schedules: []Schedule{
+				{Name: "ai", Phase: PhaseAI, After: []string{"health"}},
+				{Name: "health", Phase: PhaseHealth},
+			},
+			err: "can't run before",
+		},
+		{
+			name:      "unknown system",
+			schedules: []Schedule{{Name: "a", After: []string{"b"}}},
+			err:       "unknown system",
+		},
+		{
+			name:      "added twice",
+			schedules: []Schedule{{Name: "a"}, {Name: "a"}},
+			err:       "added twice",
+		},
+	}
+	for _, tt := range tests {
+		w := NewWorld(1000, 600, 1)
+		var ran []string
+		for _, schedule := range tt.schedules {
+			w.AddSystem(&namedSystem{name: schedule.Name, ran: &ran}, schedule)
+		}
+		err := w.Schedule()
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: expected an error containing %q, got %v", tt.name, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		w.Update(0)
+		if !slices.Equal(ran, tt.order) {
+			t.Errorf("%s: got %v, want %v", tt.name, ran, tt.order)
+		}
+	}
+}
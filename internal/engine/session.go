@@ -0,0 +1,140 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+
+	"cfichtmueller.com/htmx-game/internal/engine/physics"
+)
+
+// Session ties a player id to the player's current entity. Sessions outlive the entity, so a
+// player can respawn after the corpse is gone. They are removed when the player leaves or is idle
+// for longer than the idle timeout.
+type Session struct {
+	ID     string
+	Player Entity
+	// Team is the team the player joined. Respawned players stay on it.
+	Team      string
+	LastInput time.Time
+	LastPoll  time.Time
+	Queue     []QueuedCommand
+}
+
+func (s *Session) lastSeen() time.Time {
+	if s.LastInput.After(s.LastPoll) {
+		return s.LastInput
+	}
+	return s.LastPoll
+}
+
+func (e *Engine) SpawnPlayer() string {
+	id := randomId()
+	cmd := Command{M: CommandJoin}
+	e.apply(id, cmd)
+	e.record(id, cmd)
+	return id
+}
+
+func (e *Engine) spawnPlayerEntity() Entity {
+	return SpawnPlayer(
+		e.World,
+		e.World.Width/2,
+		e.World.Height/2,
+		physics.Deg0,
+	)
+}
+
+// Respawn queues killing the current player of the session and spawning a new one under the
+// same id and team.
+func (e *Engine) Respawn(id string) error {
+	return e.Enqueue(id, Command{M: CommandRespawn})
+}
+
+// apply runs any command, including the ones the engine records on its own.
+func (e *Engine) apply(id string, cmd Command) error {
+	switch cmd.M {
+	case CommandJoin:
+		player := e.spawnPlayerEntity()
+		e.World.joinTeam(player)
+		session := &Session{
+			ID:       id,
+			Player:   player,
+			LastPoll: time.Now(),
+		}
+		if team, ok := e.World.Components.Teams.Get(player); ok {
+			session.Team = team.Name
+		}
+		e.sessions[id] = session
+		return nil
+	case CommandLeave:
+		if session, ok := e.sessions[id]; ok {
+			e.World.RemoveEntity(session.Player)
+			delete(e.sessions, id)
+		}
+		return nil
+	case CommandTimeScale:
+		return e.setTimeScale(cmd.V)
+	}
+
+	session, ok := e.sessions[id]
+	if !ok {
+		return fmt.Errorf("unknown player %s", id)
+	}
+	switch cmd.M {
+	case CommandSetVelocity:
+		return SetEntityVelocity(e.World, session.Player, cmd.V)
+	case CommandSetRotation:
+		return SetEntityDirection(e.World, session.Player, cmd.V)
+	case CommandRespawn:
+		e.World.Kill(session.Player, NoEntity)
+		player := e.spawnPlayerEntity()
+		if session.Team != "" {
+			e.World.Components.Teams.Add(player, &Team{Name: session.Team})
+		}
+		session.Player = player
+		session.LastInput = time.Now()
+		return nil
+	}
+	return fmt.Errorf("%w %s", ErrUnknownCommand, cmd.M)
+}
+
+// PlayerCount returns the number of active player sessions.
+func (e *Engine) PlayerCount() int {
+	return len(e.sessions)
+}
+
+func (e *Engine) PlayerWithId(id string) (Entity, bool) {
+	session, ok := e.sessions[id]
+	if !ok {
+		return NoEntity, false
+	}
+	return session.Player, true
+}
+
+// RecordInput marks the session as active because the player sent a command.
+func (e *Engine) RecordInput(id string) {
+	if session, ok := e.sessions[id]; ok {
+		session.LastInput = time.Now()
+	}
+}
+
+// RecordPoll marks the session as active because the player's page polled the field.
+func (e *Engine) RecordPoll(id string) {
+	if session, ok := e.sessions[id]; ok {
+		session.LastPoll = time.Now()
+	}
+}
+
+// reapIdleSessions removes idle players. Replays remove players as recorded instead.
+func (e *Engine) reapIdleSessions(now time.Time) {
+	if e.replay != nil {
+		return
+	}
+	for _, id := range sortedKeys(e.sessions) {
+		if now.Sub(e.sessions[id].lastSeen()) > e.idleTimeout {
+			cmd := Command{M: CommandLeave}
+			e.apply(id, cmd)
+			e.record(id, cmd)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package engine
+
+import "testing"
+
+func TestRespawnAfterCorpseIsRemoved(t *testing.T) {
+	e := New(1000, 600, WithSeed(1), WithTeams("red", "blue"))
+	if err := e.World.Schedule(); err != nil {
+		t.Fatal(err)
+	}
+	id := e.SpawnPlayer()
+	player, _ := e.PlayerWithId(id)
+	team, _ := e.World.Components.Teams.Get(player)
+	teamName := team.Name
+
+	e.World.Kill(player, NoEntity)
+	e.World.RemoveEntity(player)
+	e.step()
+	if e.World.Alive(player) {
+		t.Fatal("expected the corpse to be removed")
+	}
+
+	if err := e.Respawn(id); err != nil {
+		t.Fatalf("expected the session to survive the corpse, got %v", err)
+	}
+	e.step()
+	respawned, ok := e.PlayerWithId(id)
+	if !ok || respawned == player || !e.World.Alive(respawned) || IsEntityDead(e.World, respawned) {
+		t.Fatalf("expected a new living player, got %d", respawned)
+	}
+	if team, ok := e.World.Components.Teams.Get(respawned); !ok || team.Name != teamName {
+		t.Errorf("expected the player to stay on team %s", teamName)
+	}
+}
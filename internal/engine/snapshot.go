@@ -0,0 +1,220 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"cfichtmueller.com/htmx-game/internal/engine/bhv"
+)
+
+// SnapshotVersion is incremented whenever the snapshot format changes incompatibly.
+const SnapshotVersion = 5
+
+type Snapshot struct {
+	Version        int                          `json:"version"`
+	Width          float64                      `json:"width"`
+	Height         float64                      `json:"height"`
+	Seed           uint64                       `json:"seed"`
+	Rand           []byte                       `json:"rand"`
+	FriendlyFire   bool                         `json:"friendlyFire"`
+	Teams          []string                     `json:"teams,omitempty"`
+	Generations    []uint32                     `json:"generations"`
+	FreeIndices    []uint32                     `json:"freeIndices"`
+	Entities       []Entity                     `json:"entities"`
+	Components     *ComponentStorage            `json:"components"`
+	BehaviorStates map[Entity][]json.RawMessage `json:"behaviorStates"`
+	SystemStates   []json.RawMessage            `json:"systemStates"`
+	Players        map[string]Entity            `json:"players"`
+	PlayerTeams    map[string]string            `json:"playerTeams,omitempty"`
+}
+
+// WriteSnapshot serializes the world and the player index to w.
+func (e *Engine) WriteSnapshot(w io.Writer) error {
+	e.Lock()
+	defer e.Unlock()
+	return e.writeSnapshot(w)
+}
+
+func (e *Engine) writeSnapshot(w io.Writer) error {
+	world := e.World
+	rand, err := world.source.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("unable to save random source: %v", err)
+	}
+
+	snapshot := Snapshot{
+		Version:        SnapshotVersion,
+		Width:          world.Width,
+		Height:         world.Height,
+		Seed:           world.Seed,
+		Rand:           rand,
+		FriendlyFire:   world.friendlyFire,
+		Teams:          world.teams,
+		Generations:    world.generations,
+		FreeIndices:    world.freeIndices,
+		Entities:       world.Entities,
+		Components:     world.Components,
+		BehaviorStates: make(map[Entity][]json.RawMessage),
+		SystemStates:   make([]json.RawMessage, 0),
+		Players:        make(map[string]Entity, len(e.sessions)),
+		PlayerTeams:    make(map[string]string),
+	}
+	for id, session := range e.sessions {
+		snapshot.Players[id] = session.Player
+		if session.Team != "" {
+			snapshot.PlayerTeams[id] = session.Team
+		}
+	}
+	world.Components.Behaviors.Each(func(entity Entity, behavior *Behavior) {
+		if err != nil {
+			return
+		}
+		var states []json.RawMessage
+		states, err = behavior.Tree.SaveState()
+		if err != nil {
+			err = fmt.Errorf("unable to save behavior of entity %d: %v", entity, err)
+		}
+		snapshot.BehaviorStates[entity] = states
+	})
+	if err != nil {
+		return err
+	}
+	for _, s := range world.systems {
+		saver, ok := s.system.(bhv.StateSaver)
+		if !ok {
+			continue
+		}
+		state, err := saver.SaveState()
+		if err != nil {
+			return fmt.Errorf("unable to save system state: %v", err)
+		}
+		snapshot.SystemStates = append(snapshot.SystemStates, state)
+	}
+
+	if err := json.NewEncoder(w).Encode(snapshot); err != nil {
+		return fmt.Errorf("unable to encode snapshot: %v", err)
+	}
+	return nil
+}
+
+// ReadSnapshot replaces the world with the one stored in the snapshot read from r.
+// It has to be called before the engine is started.
+func (e *Engine) ReadSnapshot(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("unable to read snapshot: %v", err)
+	}
+	e.Lock()
+	defer e.Unlock()
+	return e.readSnapshot(data)
+}
+
+func (e *Engine) readSnapshot(data []byte) error {
+	var header struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return fmt.Errorf("unable to decode snapshot: %v", err)
+	}
+	if header.Version != SnapshotVersion {
+		return fmt.Errorf("unsupported snapshot version %d, expected %d", header.Version, SnapshotVersion)
+	}
+
+	snapshot := Snapshot{Components: e.World.Components}
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return fmt.Errorf("unable to decode snapshot: %v", err)
+	}
+
+	world := e.World
+	if err := world.source.UnmarshalBinary(snapshot.Rand); err != nil {
+		return fmt.Errorf("unable to restore random source: %v", err)
+	}
+	world.Width = snapshot.Width
+	world.Height = snapshot.Height
+	world.Seed = snapshot.Seed
+	world.friendlyFire = snapshot.FriendlyFire
+	world.teams = snapshot.Teams
+	world.generations = snapshot.Generations
+	world.freeIndices = snapshot.FreeIndices
+	world.Entities = snapshot.Entities
+	world.entitiesToRemove = make(map[Entity]bool)
+	world.spatialValid = false
+
+	var err error
+	world.Components.Behaviors.Each(func(entity Entity, behavior *Behavior) {
+		if err != nil {
+			return
+		}
+		factory, ok := behaviorFactories[behavior.Name]
+		if !ok {
+			err = fmt.Errorf("entity %d has unknown behavior %s", entity, behavior.Name)
+			return
+		}
+		behavior.Tree = factory(world, entity)
+		if loadErr := behavior.Tree.LoadState(snapshot.BehaviorStates[entity]); loadErr != nil {
+			err = fmt.Errorf("unable to restore behavior of entity %d: %v", entity, loadErr)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	i := 0
+	for _, s := range world.systems {
+		saver, ok := s.system.(bhv.StateSaver)
+		if !ok {
+			continue
+		}
+		if i >= len(snapshot.SystemStates) {
+			return fmt.Errorf("snapshot is missing system states")
+		}
+		if err := saver.LoadState(snapshot.SystemStates[i]); err != nil {
+			return fmt.Errorf("unable to restore system state: %v", err)
+		}
+		i++
+	}
+
+	e.seed = snapshot.Seed
+	e.sessions = make(map[string]*Session, len(snapshot.Players))
+	now := time.Now()
+	for id, player := range snapshot.Players {
+		e.sessions[id] = &Session{ID: id, Player: player, Team: snapshot.PlayerTeams[id], LastPoll: now}
+	}
+	e.populated = true
+	return nil
+}
+
+// SaveSnapshot writes a snapshot to the file at path. The file is replaced atomically.
+func (e *Engine) SaveSnapshot(path string) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("unable to create snapshot file: %v", err)
+	}
+	defer os.Remove(f.Name())
+
+	if err := e.WriteSnapshot(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("unable to write snapshot file: %v", err)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("unable to replace snapshot file: %v", err)
+	}
+	return nil
+}
+
+// LoadSnapshot reads a snapshot from the file at path. See ReadSnapshot.
+func (e *Engine) LoadSnapshot(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("unable to open snapshot file: %w", err)
+	}
+	defer f.Close()
+	return e.ReadSnapshot(f)
+}
@@ -0,0 +1,53 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func positions(w *World) string {
+	var b bytes.Buffer
+	for _, entity := range w.Entities {
+		if pos, ok := w.Components.Positions.Get(entity); ok {
+			fmt.Fprintf(&b, "%d:%v,%v,%v;", entity, pos.X, pos.Y, pos.Direction)
+		}
+	}
+	return b.String()
+}
+
+func TestSnapshotAfterConfigChange(t *testing.T) {
+	a := New(1000, 600, WithSeed(42))
+	if err := a.Init(); err != nil {
+		t.Fatal(err)
+	}
+	a.SpawnPlayer()
+	a.Step(300)
+	config := DefaultConfig()
+	config.Tower.AimInterval = Range{Min: 0.5, Max: 1}
+	config.Tower.BurstInterval = 0.1
+	if err := a.SetConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	a.Step(100)
+
+	var snapshot bytes.Buffer
+	if err := a.WriteSnapshot(&snapshot); err != nil {
+		t.Fatal(err)
+	}
+	// Like replays, the restored engine starts with the config that was current.
+	b := New(10, 10)
+	if err := b.SetConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.ReadSnapshot(&snapshot); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 600; i++ {
+		a.Step(1)
+		b.Step(1)
+		if positions(a.World) != positions(b.World) {
+			t.Fatalf("restored world diverged after %d steps", i+1)
+		}
+	}
+}
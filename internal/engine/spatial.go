@@ -0,0 +1,174 @@
+package engine
+
+import (
+	"math"
+	"slices"
+
+	"cfichtmueller.com/htmx-game/internal/engine/physics"
+)
+
+// spatialCellSize is the edge length of a grid cell. It should exceed the size of most entities.
+const spatialCellSize = 64
+
+type spatialCell struct {
+	x, y int32
+}
+
+// spatialEntry is an entity as it was when the index was built.
+type spatialEntry struct {
+	entity   Entity
+	position physics.Vec2
+	typ      EntityType
+}
+
+// spatialIndex is a uniform grid. Every entity with a position is put into all cells that the
+// circle around its collision shape overlaps. Swept entities cover their whole path.
+type spatialIndex struct {
+	cells map[spatialCell][]spatialEntry
+	// stamps marks the entities that were already visited by the current query, by entity index.
+	stamps []uint32
+	stamp  uint32
+}
+
+func newSpatialIndex() *spatialIndex {
+	return &spatialIndex{
+		cells:  make(map[spatialCell][]spatialEntry),
+		stamps: make([]uint32, 0),
+	}
+}
+
+func (s *spatialIndex) rebuild(components *ComponentStorage) {
+	for c, entries := range s.cells {
+		if len(entries) == 0 {
+			delete(s.cells, c)
+		} else {
+			s.cells[c] = entries[:0]
+		}
+	}
+	components.Positions.Each(func(entity Entity, pos *physics.Position) {
+		entry := spatialEntry{entity: entity, position: pos.Vec2}
+		if t, ok := components.EntityTypes.Get(entity); ok {
+			entry.typ = t.Type
+		}
+		lo, hi := pos.Vec2, pos.Vec2
+		if sweep, ok := components.Sweeps.Get(entity); ok {
+			lo = physics.Vec2{X: math.Min(lo.X, sweep.From.X), Y: math.Min(lo.Y, sweep.From.Y)}
+			hi = physics.Vec2{X: math.Max(hi.X, sweep.From.X), Y: math.Max(hi.Y, sweep.From.Y)}
+		}
+		r := boundingRadius(components, entity)
+		s.eachCell(lo.Sub(physics.Vec2{X: r, Y: r}), hi.Add(physics.Vec2{X: r, Y: r}), func(c spatialCell) {
+			s.cells[c] = append(s.cells[c], entry)
+		})
+		for int(entity.Index()) >= len(s.stamps) {
+			s.stamps = append(s.stamps, 0)
+		}
+	})
+}
+
+// eachCell calls f for all cells that overlap the rectangle from min to max.
+func (s *spatialIndex) eachCell(min, max physics.Vec2, f func(c spatialCell)) {
+	x0, y0 := cellCoord(min.X), cellCoord(min.Y)
+	x1, y1 := cellCoord(max.X), cellCoord(max.Y)
+	for y := y0; y <= y1; y++ {
+		for x := x0; x <= x1; x++ {
+			f(spatialCell{x: x, y: y})
+		}
+	}
+}
+
+// near returns the entities in the cells that overlap the rectangle from min to max and that
+// keep accepts, each once, ordered by index.
+func (s *spatialIndex) near(min, max physics.Vec2, keep func(entry *spatialEntry) bool) []Entity {
+	s.stamp++
+	if s.stamp == 0 {
+		clear(s.stamps)
+		s.stamp = 1
+	}
+	entities := make([]Entity, 0)
+	collect := func(c spatialCell) {
+		entries := s.cells[c]
+		for i := range entries {
+			entry := &entries[i]
+			index := entry.entity.Index()
+			if s.stamps[index] == s.stamp {
+				continue
+			}
+			s.stamps[index] = s.stamp
+			if keep == nil || keep(entry) {
+				entities = append(entities, entry.entity)
+			}
+		}
+	}
+	x0, y0 := cellCoord(min.X), cellCoord(min.Y)
+	x1, y1 := cellCoord(max.X), cellCoord(max.Y)
+	if (int64(x1)-int64(x0)+1)*(int64(y1)-int64(y0)+1) > int64(len(s.cells)) {
+		// Large areas visit the occupied cells only.
+		for c := range s.cells {
+			if c.x >= x0 && c.x <= x1 && c.y >= y0 && c.y <= y1 {
+				collect(c)
+			}
+		}
+	} else {
+		s.eachCell(min, max, collect)
+	}
+	slices.SortFunc(entities, func(a, b Entity) int {
+		return int(a.Index()) - int(b.Index())
+	})
+	return entities
+}
+
+func cellCoord(v float64) int32 {
+	return int32(max(math.MinInt32, min(math.MaxInt32, math.Floor(v/spatialCellSize))))
+}
+
+// boundingRadius returns the radius of the collision shape of the entity, or 0 if it has none.
+func boundingRadius(components *ComponentStorage, entity Entity) float64 {
+	if !components.BoundingBoxes.Has(entity) {
+		return 0
+	}
+	return collisionShape(components, entity).Radius()
+}
+
+// orderOf maps the index of each entity to its position in entities, reusing order.
+// Other indices map to -1.
+func (w *World) orderOf(order []int, entities []Entity) []int {
+	order = order[:0]
+	for range w.generations {
+		order = append(order, -1)
+	}
+	for i, entity := range entities {
+		order[entity.Index()] = i
+	}
+	return order
+}
+
+// spatial returns the index. It is rebuilt after every system, so entities that move during a
+// system are found at their previous position until the system is done.
+func (w *World) spatial() *spatialIndex {
+	if !w.spatialValid {
+		w.spatialIndex.rebuild(w.Components)
+		w.spatialValid = true
+	}
+	return w.spatialIndex
+}
+
+// QueryRect returns the entities whose position is inside the rectangle from min to max, ordered by index.
+func (w *World) QueryRect(min, max physics.Vec2) []Entity {
+	return w.spatial().near(min, max, func(entry *spatialEntry) bool {
+		p := entry.position
+		return p.X >= min.X && p.X <= max.X && p.Y >= min.Y && p.Y <= max.Y
+	})
+}
+
+// QueryRadius returns the entities whose position is at most r away from center, ordered by index.
+func (w *World) QueryRadius(center physics.Vec2, r float64) []Entity {
+	return w.queryRadius(center, r, nil)
+}
+
+// queryRadius is QueryRadius for the entity types that keep accepts.
+func (w *World) queryRadius(center physics.Vec2, r float64, keep func(t EntityType) bool) []Entity {
+	d := physics.Vec2{X: r, Y: r}
+	return w.spatial().near(center.Sub(d), center.Add(d), func(entry *spatialEntry) bool {
+		return (keep == nil || keep(entry.typ)) && center.Sub(entry.position).Len() <= r
+	})
+}
@@ -0,0 +1,117 @@
+package engine
+
+import (
+	"slices"
+	"testing"
+
+	"cfichtmueller.com/htmx-game/internal/engine/physics"
+)
+
+func spatialWorld(points ...physics.Vec2) (*World, []Entity) {
+	w := NewWorld(1000, 600, 1)
+	entities := make([]Entity, len(points))
+	for i, p := range points {
+		entities[i] = w.AddEntity(Bullet)
+		w.Components.Positions.Add(entities[i], physics.NewPosition(p.X, p.Y, 0))
+	}
+	return w, entities
+}
+
+func TestQueryRect(t *testing.T) {
+	w, e := spatialWorld(
+		physics.Vec2{X: 10, Y: 10},
+		physics.Vec2{X: 100, Y: 10},
+		physics.Vec2{X: 63, Y: 64},
+		physics.Vec2{X: -5, Y: -5},
+		physics.Vec2{X: 500, Y: 500},
+	)
+	tests := []struct {
+		name     string
+		min, max physics.Vec2
+		want     []Entity
+	}{
+		{"one cell", physics.Vec2{X: 0, Y: 0}, physics.Vec2{X: 20, Y: 20}, []Entity{e[0]}},
+		{"across cells", physics.Vec2{X: 0, Y: 0}, physics.Vec2{X: 100, Y: 100}, []Entity{e[0], e[1], e[2]}},
+		{"edges are inside", physics.Vec2{X: 63, Y: 64}, physics.Vec2{X: 100, Y: 100}, []Entity{e[2]}},
+		{"negative", physics.Vec2{X: -10, Y: -10}, physics.Vec2{X: 0, Y: 0}, []Entity{e[3]}},
+		{"empty", physics.Vec2{X: 200, Y: 200}, physics.Vec2{X: 300, Y: 300}, nil},
+		// Areas with more cells than the index has occupied cells only visit the occupied ones.
+		{"large", physics.Vec2{X: -1e9, Y: -1e9}, physics.Vec2{X: 1e9, Y: 1e9}, e},
+		{"large without the far one", physics.Vec2{X: -1e9, Y: -1e9}, physics.Vec2{X: 499, Y: 1e9}, e[:4]},
+	}
+	for _, tt := range tests {
+		if got := w.QueryRect(tt.min, tt.max); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestQueryRadius(t *testing.T) {
+	w, e := spatialWorld(
+		physics.Vec2{X: 100, Y: 100},
+		physics.Vec2{X: 130, Y: 140},
+		physics.Vec2{X: 140, Y: 140},
+		physics.Vec2{X: 1000, Y: 100},
+	)
+	center := physics.Vec2{X: 100, Y: 100}
+	tests := []struct {
+		r    float64
+		want []Entity
+	}{
+		{0, []Entity{e[0]}},
+		{50, []Entity{e[0], e[1]}},
+		{60, []Entity{e[0], e[1], e[2]}},
+		{1e9, e},
+	}
+	for _, tt := range tests {
+		if got := w.QueryRadius(center, tt.r); !slices.Equal(got, tt.want) {
+			t.Errorf("r=%v: got %v, want %v", tt.r, got, tt.want)
+		}
+	}
+}
+
+func TestSpatialIndexFollowsChanges(t *testing.T) {
+	w, e := spatialWorld(physics.Vec2{X: 10, Y: 10}, physics.Vec2{X: 20, Y: 20})
+	origin := physics.Vec2{}
+	if got := w.QueryRadius(origin, 30); len(got) != 2 {
+		t.Fatalf("got %v, want both entities", got)
+	}
+
+	pos, _ := w.Components.Positions.Get(e[0])
+	pos.X = 900
+	w.RemoveEntity(e[1])
+	w.Update(0)
+	added := w.AddEntity(Bullet)
+	w.Components.Positions.Add(added, physics.NewPosition(5, 5, 0))
+
+	if got := w.QueryRadius(origin, 30); !slices.Equal(got, []Entity{added}) {
+		t.Errorf("got %v, want only %d", got, added)
+	}
+	if got := w.QueryRadius(physics.Vec2{X: 900, Y: 10}, 1); !slices.Equal(got, []Entity{e[0]}) {
+		t.Errorf("got %v, want the moved entity %d", got, e[0])
+	}
+}
+
+// BenchmarkStepCrowded steps a large battle. At 30 ticks/s a step may take about 33ms.
+func BenchmarkStepCrowded(b *testing.B) {
+	config := DefaultConfig()
+	config.Bullet.TTL = 1000
+	config.Tank.TTL = 1000
+	e := New(3000, 3000, WithSeed(3), WithTickRate(30), WithConfig(config))
+	if err := e.Init(); err != nil {
+		b.Fatal(err)
+	}
+	w := e.World
+	for i := 0; i < 3000; i++ {
+		SpawnBullet(w, NoEntity, w.frandom(0, 3000), w.frandom(0, 3000), w.frandom(0, physics.Deg360))
+	}
+	for i := 0; i < 300; i++ {
+		SpawnTank(w, w.frandom(0, 3000), w.frandom(0, 3000), w.frandom(0, physics.Deg360))
+	}
+	e.Step(1)
+	entities := len(w.Entities)
+	b.ResetTimer()
+	e.Step(b.N)
+	b.ReportMetric(float64(entities), "entities")
+	b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "ticks/s")
+}
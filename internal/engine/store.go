@@ -0,0 +1,205 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// Store holds the components of type T. Components are kept in insertion order,
+// removing a component moves the last one into its place.
+type Store[T any] struct {
+	name    string
+	changed func(entity Entity)
+	// index maps the index of an entity to the position of its component, or -1. An entity
+	// index is only used by one generation at a time, so the entity at that position tells
+	// whether the component belongs to it.
+	index    []int32
+	entities []Entity
+	items    []*T
+}
+
+func newStore[T any](name string) *Store[T] {
+	return &Store[T]{
+		name:     name,
+		changed:  func(entity Entity) {},
+		index:    make([]int32, 0),
+		entities: make([]Entity, 0),
+		items:    make([]*T, 0),
+	}
+}
+
+// find returns the position of the component of entity.
+func (s *Store[T]) find(entity Entity) (int, bool) {
+	index := entity.Index()
+	if int(index) >= len(s.index) {
+		return 0, false
+	}
+	i := s.index[index]
+	if i < 0 || s.entities[i] != entity {
+		return 0, false
+	}
+	return int(i), true
+}
+
+func (s *Store[T]) Name() string {
+	return s.name
+}
+
+// Add sets the component of entity, replacing an existing one.
+func (s *Store[T]) Add(entity Entity, component *T) *T {
+	if i, ok := s.find(entity); ok {
+		s.items[i] = component
+		return component
+	}
+	for int(entity.Index()) >= len(s.index) {
+		s.index = append(s.index, -1)
+	}
+	s.index[entity.Index()] = int32(len(s.entities))
+	s.entities = append(s.entities, entity)
+	s.items = append(s.items, component)
+	s.changed(entity)
+	return component
+}
+
+func (s *Store[T]) Get(entity Entity) (*T, bool) {
+	i, ok := s.find(entity)
+	if !ok {
+		return nil, false
+	}
+	return s.items[i], true
+}
+
+func (s *Store[T]) Has(entity Entity) bool {
+	_, ok := s.find(entity)
+	return ok
+}
+
+func (s *Store[T]) Remove(entity Entity) {
+	i, ok := s.find(entity)
+	if !ok {
+		return
+	}
+	last := len(s.entities) - 1
+	if i != last {
+		s.entities[i] = s.entities[last]
+		s.items[i] = s.items[last]
+		s.index[s.entities[i].Index()] = int32(i)
+	}
+	s.entities = s.entities[:last]
+	s.items[last] = nil
+	s.items = s.items[:last]
+	s.index[entity.Index()] = -1
+	s.changed(entity)
+}
+
+// Each calls f for every component in the store.
+func (s *Store[T]) Each(f func(entity Entity, component *T)) {
+	for i := 0; i < len(s.entities); i++ {
+		f(s.entities[i], s.items[i])
+	}
+}
+
+// EachEntity calls f for every entity that has a component in the store.
+func (s *Store[T]) EachEntity(f func(entity Entity)) {
+	for i := 0; i < len(s.entities); i++ {
+		f(s.entities[i])
+	}
+}
+
+func (s *Store[T]) Len() int {
+	return len(s.entities)
+}
+
+type storeEntry[T any] struct {
+	Entity    Entity `json:"e"`
+	Component *T     `json:"c"`
+}
+
+func (s *Store[T]) MarshalJSON() ([]byte, error) {
+	entries := make([]storeEntry[T], len(s.entities))
+	for i := range s.entities {
+		entries[i] = storeEntry[T]{Entity: s.entities[i], Component: s.items[i]}
+	}
+	return json.Marshal(entries)
+}
+
+func (s *Store[T]) UnmarshalJSON(data []byte) error {
+	var entries []storeEntry[T]
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+	s.clear()
+	for _, entry := range entries {
+		s.Add(entry.Entity, entry.Component)
+	}
+	return nil
+}
+
+// validate checks that data decodes into a component.
+func (s *Store[T]) validate(data json.RawMessage) error {
+	return json.Unmarshal(data, new(T))
+}
+
+// decode sets the component of entity to the one decoded from data.
+func (s *Store[T]) decode(entity Entity, data json.RawMessage) error {
+	component := new(T)
+	if err := json.Unmarshal(data, component); err != nil {
+		return err
+	}
+	s.Add(entity, component)
+	return nil
+}
+
+func (s *Store[T]) clear() {
+	entities := s.entities
+	s.index = make([]int32, 0)
+	s.entities = make([]Entity, 0)
+	s.items = make([]*T, 0)
+	for _, entity := range entities {
+		s.changed(entity)
+	}
+}
+
+// store is the part of Store that doesn't depend on the component type.
+type store interface {
+	json.Marshaler
+	json.Unmarshaler
+	Name() string
+	Has(entity Entity) bool
+	Remove(entity Entity)
+	EachEntity(f func(entity Entity))
+	Len() int
+	validate(data json.RawMessage) error
+	decode(entity Entity, data json.RawMessage) error
+	clear()
+}
+
+// RegisterStore adds a store for components of type T to s. Components in registered stores are removed
+// together with their entity. It panics if a store with the same name or component type already exists.
+func RegisterStore[T any](s *ComponentStorage, name string) *Store[T] {
+	t := reflect.TypeFor[T]()
+	if _, ok := s.byName[name]; ok {
+		panic(fmt.Errorf("component store %s is already registered", name))
+	}
+	if existing, ok := s.byType[t]; ok {
+		panic(fmt.Errorf("components of type %v are already stored in %s", t, existing.Name()))
+	}
+	store := newStore[T](name)
+	store.changed = func(entity Entity) {
+		s.componentChanged(store, entity)
+	}
+	s.stores = append(s.stores, store)
+	s.byName[name] = store
+	s.byType[t] = store
+	return store
+}
+
+// StoreFor returns the registered store for components of type T.
+func StoreFor[T any](s *ComponentStorage) (*Store[T], bool) {
+	store, ok := s.byType[reflect.TypeFor[T]()]
+	if !ok {
+		return nil, false
+	}
+	return store.(*Store[T]), true
+}
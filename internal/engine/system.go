@@ -1,12 +1,28 @@
 package engine
 
 import (
+	"encoding/json"
 	"math"
+	"slices"
+	"sort"
 
 	"cfichtmueller.com/htmx-game/internal/engine/bhv"
 	"cfichtmueller.com/htmx-game/internal/engine/physics"
 )
 
+const (
+	SystemAutoMove           = "autoMove"
+	SystemBehavior           = "behavior"
+	SystemCollisionDetection = "collisionDetection"
+	SystemHealth             = "health"
+	SystemInput              = "input"
+	SystemMovement           = "movement"
+	SystemRigidBody          = "rigidBody"
+	SystemSensing            = "sensing"
+	SystemSpeedPowerUp       = "speedPowerUp"
+	SystemTransform          = "transform"
+)
+
 type System interface {
 	Update(entities []Entity, components *ComponentStorage, dt float64)
 }
@@ -18,17 +34,11 @@ func NewAutoMoveSystem() *AutoMoveSystem {
 }
 
 func (s *AutoMoveSystem) Update(entities []Entity, components *ComponentStorage, dt float64) {
-	for _, entity := range entities {
-		autoMove, hasAutoMove := components.AutoMove[entity]
-		if !hasAutoMove || !autoMove.TargetDirectionActive {
-			continue
-		}
-		position, hasPosition := components.Positions[entity]
-		acceleration, hasAcceleration := components.Accelerations[entity]
-		velocity, hasVelocity := components.Velocities[entity]
-		if !hasPosition || !hasVelocity {
-			continue
+	Each3(components, func(entity Entity, autoMove *AutoMove, position *physics.Position, velocity *Velocity) {
+		if !autoMove.TargetDirectionActive {
+			return
 		}
+		acceleration, hasAcceleration := components.Accelerations.Get(entity)
 
 		delta := physics.ShortesRotationDirection(position.Direction, autoMove.TargetDirection)
 		if math.Abs(delta) > physics.Deg1*5 {
@@ -42,10 +52,11 @@ func (s *AutoMoveSystem) Update(entities []Entity, components *ComponentStorage,
 				acceleration.AngularCurrent = 0
 			}
 			velocity.AngularCurrent = 0
+			velocity.Turn(autoMove.TargetDirection - position.Direction)
 			position.Direction = autoMove.TargetDirection
 			autoMove.TargetDirectionActive = false
 		}
-	}
+	})
 }
 
 type BehaviorSystem struct{}
@@ -55,32 +66,40 @@ func NewBehaviorSystem() *BehaviorSystem {
 }
 
 func (s *BehaviorSystem) Update(entities []Entity, components *ComponentStorage, dt float64) {
-	for _, entity := range entities {
-		behavior, hasBehavior := components.Behaviors[entity]
-		if !hasBehavior {
-			continue
-		}
+	Each(components, func(entity Entity, behavior *Behavior) {
 		behavior.Tree.Tick(dt)
-	}
+	})
 }
 
 type Collision struct {
 	EntityA, EntityB Entity
+	// Time is the fraction of the tick at which the entities started to touch. Without a sweep
+	// the entities are only tested where they ended up, so it is 1.
+	Time float64
 }
 
 type CollisionHandler interface {
 	HandleCollision(entityA, entityB Entity, components *ComponentStorage, dt float64)
 }
 
+// CollisionDetectionSystem tests entities that are close to each other according to the
+// spatial index and have a handler for their types. Swept entities are tested along their
+// path and their collisions are handled by time of impact, after the others.
 type CollisionDetectionSystem struct {
+	world      *World
 	collisions []Collision
+	swept      []Collision
 	handlers   map[EntityType]map[EntityType]CollisionHandler
+	order      []int
 }
 
-func NewCollisionDetectionSystem() *CollisionDetectionSystem {
+func NewCollisionDetectionSystem(world *World) *CollisionDetectionSystem {
 	return &CollisionDetectionSystem{
+		world:      world,
 		collisions: make([]Collision, 0),
+		swept:      make([]Collision, 0),
 		handlers:   make(map[EntityType]map[EntityType]CollisionHandler),
+		order:      make([]int, 0),
 	}
 }
 
@@ -93,32 +112,109 @@ func (s *CollisionDetectionSystem) RegisterHandler(typeA, typeB EntityType, hand
 
 func (s *CollisionDetectionSystem) Update(entities []Entity, components *ComponentStorage, dt float64) {
 	s.collisions = s.collisions[:0]
+	s.swept = s.swept[:0]
+
+	colliders := components.Query(With[physics.Position], With[physics.Rectangle]).Entities()
+	shapes := make([]physics.Shape, len(colliders))
+	s.order = s.world.orderOf(s.order, colliders)
+	for i, entity := range colliders {
+		shapes[i] = collisionShape(components, entity)
+	}
+
+	// Pairs are tested in the order of the colliders, like testing all pairs would.
+	index := s.world.spatial()
+	for a, entityA := range colliders {
+		posA, _ := components.Positions.Get(entityA)
+		fromA, sweptA := sweptFrom(components, entityA, posA)
+		typeA := s.world.EntityType(entityA)
+		r := physics.Vec2{X: shapes[a].Radius(), Y: shapes[a].Radius()}
+		lo := physics.Vec2{X: math.Min(posA.X, fromA.X), Y: math.Min(posA.Y, fromA.Y)}.Sub(r)
+		hi := physics.Vec2{X: math.Max(posA.X, fromA.X), Y: math.Max(posA.Y, fromA.Y)}.Add(r)
+
+		for _, entityB := range index.near(lo, hi, nil) {
+			b := s.order[entityB.Index()]
+			if b <= a || !s.handles(typeA, s.world.EntityType(entityB)) {
+				continue
+			}
+			posB, _ := components.Positions.Get(entityB)
+			fromB, sweptB := sweptFrom(components, entityB, posB)
+
+			if !sweptA && !sweptB {
+				if physics.Collides(shapes[a], posA, shapes[b], posB) {
+					s.collisions = append(s.collisions, Collision{EntityA: entityA, EntityB: entityB, Time: 1})
+					s.handleCollision(entityA, entityB, components, dt)
+				}
+				continue
+			}
 
-	for a := 0; a < len(entities); a++ {
-		for b := a + 1; b < len(entities); b++ {
-			entityA := entities[a]
-			entityB := entities[b]
-
-			posA, hasPosA := components.Positions[entityA]
-			bbA, hasBBA := components.BoundingBoxes[entityA]
-			posB, hasPosB := components.Positions[entityB]
-			bbB, hasBBB := components.BoundingBoxes[entityB]
-
-			if hasPosA &&
-				hasBBA &&
-				hasPosB &&
-				hasBBB &&
-				physics.Collides(posA, bbA, posB, bbB) {
-				s.collisions = append(s.collisions, Collision{EntityA: entityA, EntityB: entityB})
-				s.handleCollision(entityA, entityB, components, dt)
+			// In the frame of the entity that is not swept, the swept one moves by the
+			// difference of both movements.
+			t, hit := 1.0, false
+			circleA, circularA := shapes[a].(physics.Circle)
+			circleB, circularB := shapes[b].(physics.Circle)
+			switch {
+			case sweptA && circularA:
+				t, hit = physics.Sweep(circleA, fromA.Add(posB.Sub(fromB)), posA.Vec2, shapes[b], posB)
+			case sweptB && circularB:
+				t, hit = physics.Sweep(circleB, fromB.Add(posA.Sub(fromA)), posB.Vec2, shapes[a], posA)
+			default:
+				// Only circles are swept, see validatePrefab.
+				hit = physics.Collides(shapes[a], posA, shapes[b], posB)
+			}
+			if hit {
+				s.swept = append(s.swept, Collision{EntityA: entityA, EntityB: entityB, Time: t})
 			}
 		}
 	}
+
+	// A swept entity that is removed by a collision doesn't reach anything it would hit later.
+	sort.SliceStable(s.swept, func(i, j int) bool {
+		return s.swept[i].Time < s.swept[j].Time
+	})
+	for _, c := range s.swept {
+		if s.removedSwept(c.EntityA, components) || s.removedSwept(c.EntityB, components) {
+			continue
+		}
+		s.collisions = append(s.collisions, c)
+		s.handleCollision(c.EntityA, c.EntityB, components, dt)
+	}
+}
+
+// sweptFrom returns where the entity was at the start of the tick and whether it is swept.
+// Entities that are not swept are treated as if they didn't move.
+func sweptFrom(components *ComponentStorage, entity Entity, pos *physics.Position) (physics.Vec2, bool) {
+	if sweep, ok := components.Sweeps.Get(entity); ok {
+		return sweep.From, true
+	}
+	return pos.Vec2, false
+}
+
+func (s *CollisionDetectionSystem) removedSwept(entity Entity, components *ComponentStorage) bool {
+	return components.Sweeps.Has(entity) && s.world.entitiesToRemove[entity]
+}
+
+func (s *CollisionDetectionSystem) handles(typeA, typeB EntityType) bool {
+	_, ok := s.handlers[typeA][typeB]
+	if !ok {
+		_, ok = s.handlers[typeB][typeA]
+	}
+	return ok
+}
+
+// collisionShape returns the collider of the entity or else its bounding box.
+func collisionShape(components *ComponentStorage, entity Entity) physics.Shape {
+	if collider, ok := components.Colliders.Get(entity); ok {
+		if shape := collider.Shape(); shape != nil {
+			return shape
+		}
+	}
+	bb, _ := components.BoundingBoxes.Get(entity)
+	return *bb
 }
 
 func (s *CollisionDetectionSystem) handleCollision(entityA, entityB Entity, components *ComponentStorage, dt float64) {
-	typeAComp, hasTypeA := components.EntityTypes[entityA]
-	typeBComp, hasTypeB := components.EntityTypes[entityB]
+	typeAComp, hasTypeA := components.EntityTypes.Get(entityA)
+	typeBComp, hasTypeB := components.EntityTypes.Get(entityB)
 
 	if !hasTypeA || !hasTypeB {
 		return
@@ -138,6 +234,86 @@ func (s *CollisionDetectionSystem) Collisions() []Collision {
 	return s.collisions
 }
 
+// RigidBodySystem pushes overlapping rigid bodies apart along the minimum translation vector.
+// It runs after the collision handlers, so they still see the bodies touch.
+type RigidBodySystem struct {
+	world *World
+	order []int
+}
+
+func NewRigidBodySystem(world *World) *RigidBodySystem {
+	return &RigidBodySystem{
+		world: world,
+		order: make([]int, 0),
+	}
+}
+
+func (s *RigidBodySystem) Update(entities []Entity, components *ComponentStorage, dt float64) {
+	bodies := components.Query(With[RigidBody], With[physics.Position], With[physics.Rectangle]).Entities()
+	s.order = s.world.orderOf(s.order, bodies)
+
+	index := s.world.spatial()
+	for a, entityA := range bodies {
+		bodyA, _ := components.RigidBodies.Get(entityA)
+		if !s.solid(entityA, bodyA) {
+			continue
+		}
+		posA, _ := components.Positions.Get(entityA)
+		shapeA := collisionShape(components, entityA)
+		r := physics.Vec2{X: shapeA.Radius(), Y: shapeA.Radius()}
+
+		for _, entityB := range index.near(posA.Sub(r), posA.Add(r), nil) {
+			if s.order[entityB.Index()] <= a {
+				continue
+			}
+			bodyB, _ := components.RigidBodies.Get(entityB)
+			if !s.solid(entityB, bodyB) || (bodyA.static() && bodyB.static()) {
+				continue
+			}
+			posB, _ := components.Positions.Get(entityB)
+			mtv, ok := physics.Collide(shapeA, posA, collisionShape(components, entityB), posB)
+			if !ok {
+				continue
+			}
+
+			invA, invB := bodyA.inverseMass(), bodyB.inverseMass()
+			posA.Vec2 = posA.Sub(mtv.Scale(invA / (invA + invB)))
+			posB.Vec2 = posB.Add(mtv.Scale(invB / (invA + invB)))
+			normal := mtv.Normalize()
+			s.bounce(entityA, bodyA, posA, normal.Scale(-1))
+			s.bounce(entityB, bodyB, posB, normal)
+		}
+	}
+}
+
+func (s *RigidBodySystem) solid(entity Entity, body *RigidBody) bool {
+	return body.static() || !IsEntityDead(s.world, entity)
+}
+
+// bounce reflects the direction of a body that moves against normal, the direction it was pushed in.
+// The speed is kept, as it is controlled by input and behaviors.
+func (s *RigidBodySystem) bounce(entity Entity, body *RigidBody, pos *physics.Position, normal physics.Vec2) {
+	if body.static() || body.Restitution == 0 {
+		return
+	}
+	velocity, ok := s.world.Components.Velocities.Get(entity)
+	if !ok {
+		return
+	}
+	v := velocity.Linear
+	vn := v.Dot(normal)
+	if vn >= 0 {
+		return
+	}
+	reversing := v.Dot(physics.FromAngle(pos.Direction)) < 0
+	v = v.Sub(normal.Scale((1 + body.Restitution) * vn))
+	velocity.Linear = v.Normalize().Scale(velocity.Linear.Len())
+	pos.Direction = v.Angle()
+	if reversing {
+		pos.Direction += math.Pi
+	}
+}
+
 type HealthSystem struct {
 	world *World
 }
@@ -147,16 +323,12 @@ func NewHealthSystem(world *World) *HealthSystem {
 }
 
 func (s *HealthSystem) Update(entities []Entity, components *ComponentStorage, dt float64) {
-	for _, entity := range entities {
-		health, hasHealth := components.Healths[entity]
-		if !hasHealth {
-			continue
-		}
+	Each(components, func(entity Entity, health *Health) {
 		if health.Ages {
 			health.TTL = math.Max(0, health.TTL-dt)
 		}
 		if health.Ages && health.TTL == 0 {
-			health.Dead = true
+			s.world.Kill(entity, NoEntity)
 		}
 		if health.Dead && health.Decays {
 			health.DecayTTL = math.Max(0, health.DecayTTL-dt)
@@ -168,7 +340,7 @@ func (s *HealthSystem) Update(entities []Entity, components *ComponentStorage, d
 		if health.Dead && (!health.Decays || health.Decayed) {
 			s.world.RemoveEntity(entity)
 		}
-	}
+	})
 }
 
 type MovementSystem struct{}
@@ -178,77 +350,84 @@ func NewMovementSystem() *MovementSystem {
 }
 
 func (s *MovementSystem) Update(entities []Entity, components *ComponentStorage, dt float64) {
-	for _, entity := range entities {
-		pos, hasPos := components.Positions[entity]
-		acceleration, hasAcceleration := components.Accelerations[entity]
-		friction, hasFriction := components.Frictions[entity]
-		velocity, hasVelocity := components.Velocities[entity]
-		health, hasHealth := components.Healths[entity]
-
-		if !hasPos || !hasVelocity {
-			continue
-		}
+	Each2(components, func(entity Entity, pos *physics.Position, velocity *Velocity) {
+		acceleration, hasAcceleration := components.Accelerations.Get(entity)
+		friction, hasFriction := components.Frictions.Get(entity)
+		health, hasHealth := components.Healths.Get(entity)
 
 		if hasHealth && health.Dead {
 			if hasAcceleration {
 				acceleration.Current = 0
 				acceleration.AngularCurrent = 0
 			}
-			velocity.Current = 0
+			velocity.Linear = physics.Vec2{}
 			velocity.AngularCurrent = 0
 		}
 
 		if hasAcceleration {
-			velocity.Current = physics.Accelerate(velocity.Current, velocity.Max, acceleration.Current, dt)
+			forward := physics.FromAngle(pos.Direction)
+			speed := velocity.Linear.Dot(forward)
+			accelerated := physics.Accelerate(speed, velocity.Max, acceleration.Current, dt)
+			velocity.Linear = velocity.Linear.Add(forward.Scale(accelerated - speed))
 			velocity.AngularCurrent = physics.Accelerate(velocity.AngularCurrent, velocity.AngularMax, acceleration.AngularCurrent, dt)
 		}
 
 		if hasFriction {
-			velocity.Current = math.Max(0, velocity.Current-friction.Current*dt)
+			if speed := velocity.Linear.Len(); speed > 0 {
+				velocity.Linear = velocity.Linear.Scale(math.Max(0, speed-friction.Current*dt) / speed)
+			}
 			velocity.AngularCurrent = math.Max(0, velocity.AngularCurrent-friction.AngularCurrent*dt)
 		}
 
-		if hasPos && hasVelocity {
-			pos.Direction += velocity.AngularCurrent * dt
-			pos.X, pos.Y = physics.Move(pos.X, pos.Y, pos.Direction, velocity.Current, dt)
+		if sweep, ok := components.Sweeps.Get(entity); ok {
+			sweep.From = pos.Vec2
 		}
-	}
+		turn := velocity.AngularCurrent * dt
+		pos.Direction += turn
+		velocity.Turn(turn)
+		pos.Vec2 = pos.Add(velocity.Linear.Scale(dt))
+	})
 }
 
-type SensingSystem struct{}
+// SensingSystem finds the entities in range of each sensing entity with the spatial index.
+type SensingSystem struct {
+	world *World
+}
 
-func NewSensingSystem() *SensingSystem {
-	return &SensingSystem{}
+func NewSensingSystem(world *World) *SensingSystem {
+	return &SensingSystem{world: world}
 }
 
 func (s *SensingSystem) Update(entities []Entity, components *ComponentStorage, dt float64) {
-	for _, entity := range entities {
-		pos, hasPos := components.Positions[entity]
-		sensing, hasSensing := components.Sensings[entity]
-
-		if !hasPos || !hasSensing {
-			continue
-		}
-
+	Each2(components, func(entity Entity, pos *physics.Position, sensing *Sensing) {
 		sensing.SensedEntities = []SensedEntity{}
 
-		for _, otherEntity := range entities {
+		maxRange := 0.0
+		for _, r := range sensing.Ranges {
+			maxRange = math.Max(maxRange, r)
+		}
+		sensed := func(t EntityType) bool {
+			_, ok := sensing.Ranges[t]
+			return ok
+		}
+		for _, otherEntity := range s.world.queryRadius(pos.Vec2, maxRange, sensed) {
 			if entity == otherEntity {
 				continue
 			}
-
-			otherPos, hasOtherPos := components.Positions[otherEntity]
-			otherType, hasOtherType := components.EntityTypes[otherEntity]
-
-			if !hasOtherPos || !hasOtherType {
+			if sensing.HostileOnly && !hostile(components, entity, otherEntity) {
 				continue
 			}
 
+			otherType, ok := components.EntityTypes.Get(otherEntity)
+			if !ok {
+				continue
+			}
 			sensingRange, hasSensingRange := sensing.Ranges[otherType.Type]
 			if !hasSensingRange {
 				continue
 			}
 
+			otherPos, _ := components.Positions.Get(otherEntity)
 			distance := physics.Distance(pos, otherPos)
 			if distance <= sensingRange {
 				sensing.SensedEntities = append(sensing.SensedEntities, SensedEntity{
@@ -258,8 +437,48 @@ func (s *SensingSystem) Update(entities []Entity, components *ComponentStorage,
 				})
 			}
 		}
+	})
+}
+
+// TransformSystem moves attached entities along with their parents. Parents are placed before their children.
+type TransformSystem struct{}
 
+func NewTransformSystem() *TransformSystem {
+	return &TransformSystem{}
+}
+
+func (s *TransformSystem) Update(entities []Entity, components *ComponentStorage, dt float64) {
+	// The query's slice is ordered by index and must not be reordered.
+	children := slices.Clone(components.Query(With[Parent], With[physics.Position]).Entities())
+	depths := make(map[Entity]int, len(children))
+	for _, child := range children {
+		depths[child] = hierarchyDepth(components, child)
 	}
+	sort.SliceStable(children, func(i, j int) bool {
+		return depths[children[i]] < depths[children[j]]
+	})
+
+	for _, child := range children {
+		parent, _ := components.Parents.Get(child)
+		parentPos, ok := components.Positions.Get(parent.Entity)
+		if !ok {
+			continue
+		}
+		pos, _ := components.Positions.Get(child)
+
+		offset := physics.Vec2{X: parent.OffsetX, Y: parent.OffsetY}.Rotate(parentPos.Direction)
+		pos.Vec2 = parentPos.Add(offset)
+		pos.Direction = parentPos.Direction + parent.Rotation
+	}
+}
+
+// hierarchyDepth returns the number of ancestors of entity. Cycles are cut off at maxHierarchyDepth.
+func hierarchyDepth(components *ComponentStorage, entity Entity) int {
+	depth := 0
+	for parent, ok := components.Parents.Get(entity); ok && depth < maxHierarchyDepth; parent, ok = components.Parents.Get(parent.Entity) {
+		depth++
+	}
+	return depth
 }
 
 type SpeedPowerUpSystem struct {
@@ -270,20 +489,15 @@ func NewSpeedPowerUpSystem(world *World) *SpeedPowerUpSystem {
 	return &SpeedPowerUpSystem{
 		behavior: bhv.NewTree(
 			bhv.SequenceNode(
-				bhv.WaitNode(&bhv.WaitState{TimeToWaitFn: frandomF(5, 7)},
+				bhv.WaitNode(&bhv.WaitState{TimeToWaitFn: func() float64 {
+					interval := world.config.SpeedPowerUp.SpawnInterval
+					return world.frandom(interval.Min, interval.Max)
+				}},
 					&bhv.Node{
 						OnTick: func(n *bhv.Node, dt float64) bhv.Status {
-							entity := world.AddEntity(SpeedPowerUp)
-							world.Components.Positions[entity] = &physics.Position{
-								X:         frandom(70, world.Width-70),
-								Y:         frandom(70, world.Height-70),
-								Direction: -physics.Deg90,
-							}
-							world.Components.BoundingBoxes[entity] = &physics.Rectangle{W: 20, H: 20}
-							world.Components.Healths[entity] = &Health{
-								Ages: true,
-								TTL:  30,
-							}
+							x := world.frandom(70, world.Width-70)
+							y := world.frandom(70, world.Height-70)
+							SpawnSpeedPowerUp(world, x, y)
 							return bhv.StatusSuccess
 						},
 					},
@@ -296,3 +510,19 @@ func NewSpeedPowerUpSystem(world *World) *SpeedPowerUpSystem {
 func (s *SpeedPowerUpSystem) Update(entities []Entity, components *ComponentStorage, dt float64) {
 	s.behavior.Tick(dt)
 }
+
+func (s *SpeedPowerUpSystem) SaveState() (json.RawMessage, error) {
+	states, err := s.behavior.SaveState()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(states)
+}
+
+func (s *SpeedPowerUpSystem) LoadState(data json.RawMessage) error {
+	var states []json.RawMessage
+	if err := json.Unmarshal(data, &states); err != nil {
+		return err
+	}
+	return s.behavior.LoadState(states)
+}
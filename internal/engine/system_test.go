@@ -0,0 +1,105 @@
+package engine
+
+import (
+	"math"
+	"testing"
+
+	"cfichtmueller.com/htmx-game/internal/engine/physics"
+)
+
+func TestFastBulletHitsFirstPlayer(t *testing.T) {
+	for _, rate := range []int{60, 10, 2} {
+		config := DefaultConfig()
+		config.Bullet.Velocity = 3000
+		e := New(1000, 600, WithSeed(1), WithTickRate(rate), WithConfig(config))
+		if err := e.Init(); err != nil {
+			t.Fatal(err)
+		}
+		w := e.World
+		first := w.mustSpawn(PrefabPlayer, 500, 300, 0)
+		second := w.mustSpawn(PrefabPlayer, 560, 300, 0)
+		bullet := w.mustSpawn(PrefabBullet, 100, 300, 0)
+		e.Step(rate)
+
+		if !IsEntityDead(w, first) || IsEntityDead(w, second) || w.Alive(bullet) {
+			t.Errorf("%d ticks/s: expected the bullet to kill only the first player", rate)
+		}
+	}
+}
+
+func TestCollisionWithoutHealth(t *testing.T) {
+	w := NewWorld(1000, 600, 1)
+	player := w.AddEntity(Player)
+	tower := w.mustSpawn(PrefabTower, 0, 0, 0)
+	NewPlayerTowerCollisionHandler(w).HandleCollision(player, tower, w.Components, 0)
+	if !IsEntityDead(w, tower) {
+		t.Error("expected a player without health to kill the tower")
+	}
+	tank := w.AddEntity(Tank)
+	player = w.mustSpawn(PrefabPlayer, 0, 0, 0)
+	NewTankPlayerCollisionHandler(w).HandleCollision(tank, player, w.Components, 0)
+	if !IsEntityDead(w, player) {
+		t.Error("expected a tank without health to kill the player")
+	}
+}
+
+func TestRigidBodies(t *testing.T) {
+	tests := []struct {
+		name       string
+		a, b       RigidBody
+		dirA, dirB float64
+		xA, xB     float64
+		expectDirA float64
+	}{
+		{"heavier moves less", RigidBody{Mass: 1}, RigidBody{Mass: 3}, 0, 0, -3, 17, 0},
+		{"static doesn't move", RigidBody{Mass: 1}, RigidBody{Static: true}, 0, 0, -4, 16, 0},
+		{"massless is static", RigidBody{Mass: 1}, RigidBody{}, 0, 0, -4, 16, 0},
+		{"both static", RigidBody{}, RigidBody{Static: true}, 0, 0, 0, 16, 0},
+		{"bounces off", RigidBody{Mass: 1, Restitution: 1}, RigidBody{Static: true}, 0, 0, -4, 16, physics.Deg180},
+		{"half bounce", RigidBody{Mass: 1, Restitution: 0.5}, RigidBody{Static: true}, physics.Deg45, 0, -4, 16, math.Atan2(2, -1)},
+		{"moving away", RigidBody{Mass: 1, Restitution: 1}, RigidBody{Static: true}, physics.Deg180, 0, -4, 16, physics.Deg180},
+	}
+	for _, tt := range tests {
+		w := NewWorld(1000, 600, 1)
+		add := func(x, direction float64, body RigidBody) *physics.Position {
+			entity := w.AddEntity(Tank)
+			pos := physics.NewPosition(x, 0, direction)
+			w.Components.Positions.Add(entity, pos)
+			w.Components.BoundingBoxes.Add(entity, &physics.Rectangle{W: 20, H: 20})
+			w.Components.Colliders.Add(entity, &Collider{Circle: &physics.Circle{R: 10}})
+			w.Components.RigidBodies.Add(entity, &body)
+			w.Components.Velocities.Add(entity, &Velocity{Linear: physics.FromAngle(direction).Scale(10)})
+			return pos
+		}
+		posA := add(0, tt.dirA, tt.a)
+		posB := add(16, tt.dirB, tt.b)
+		NewRigidBodySystem(w).Update(nil, w.Components, 1.0/60)
+
+		if math.Abs(posA.X-tt.xA) > 1e-9 || math.Abs(posB.X-tt.xB) > 1e-9 {
+			t.Errorf("%s: got x %v and %v, want %v and %v", tt.name, posA.X, posB.X, tt.xA, tt.xB)
+		}
+		if math.Abs(math.Remainder(posA.Direction-tt.expectDirA, 2*math.Pi)) > 1e-9 {
+			t.Errorf("%s: got direction %v, want %v", tt.name, posA.Direction, tt.expectDirA)
+		}
+	}
+}
+
+func TestMovementTurnsVelocity(t *testing.T) {
+	w := NewWorld(1000, 600, 1)
+	entity := w.AddEntity(Tank)
+	pos := physics.NewPosition(0, 0, 0)
+	w.Components.Positions.Add(entity, pos)
+	velocity := &Velocity{Linear: physics.Vec2{X: 10}, AngularCurrent: physics.Deg90}
+	w.Components.Velocities.Add(entity, velocity)
+
+	NewMovementSystem().Update(nil, w.Components, 1)
+	if pos.Direction != physics.Deg90 || pos.Sub(physics.Vec2{Y: 10}).Len() > 1e-9 {
+		t.Errorf("expected to drive forward after turning, got %v", pos)
+	}
+	if err := SetEntityDirection(w, entity, physics.Deg180); err != nil {
+		t.Fatal(err)
+	}
+	if velocity.Linear.Sub(physics.Vec2{X: -10}).Len() > 1e-9 {
+		t.Errorf("expected the velocity to turn with the entity, got %v", velocity.Linear)
+	}
+}
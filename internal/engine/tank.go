@@ -5,53 +5,72 @@ import (
 	"cfichtmueller.com/htmx-game/internal/engine/physics"
 )
 
-func SpawnTankShelter(world *World, x, y, direction float64) {
-	entity := world.AddEntity(TankShelter)
-	world.Components.Positions[entity] = &physics.Position{X: x, Y: y, Direction: direction}
-	world.Components.BoundingBoxes[entity] = &physics.Rectangle{W: 30, H: 30}
-	world.Components.Behaviors[entity] = &Behavior{
-		Tree: bhv.NewTree(
-			bhv.WaitNode(
-				&bhv.WaitState{TimeToWaitFn: frandomF(2, 4)},
-				&bhv.Node{OnTick: func(n *bhv.Node, dt float64) bhv.Status {
-					SpawnTank(
-						world,
-						x,
-						y,
-						direction,
-					)
-					return bhv.StatusSuccess
-				}},
-			),
+const (
+	PrefabTankShelter = "tankShelter"
+	PrefabTank        = "tank"
+)
+
+func SpawnTankShelter(world *World, x, y, direction float64) Entity {
+	return world.mustSpawn(PrefabTankShelter, x, y, direction)
+}
+
+// tankShelterBehavior spawns entities as configured by the shelter's Spawner. They join the shelter's team.
+func tankShelterBehavior(world *World, entity Entity) *bhv.Tree {
+	return bhv.NewTree(
+		bhv.WaitNode(
+			&bhv.WaitState{TimeToWaitFn: func() float64 {
+				spawner, ok := world.Components.Spawners.Get(entity)
+				if !ok {
+					return 0
+				}
+				return world.frandom(spawner.IntervalMin, spawner.IntervalMax)
+			}},
+			&bhv.Node{OnTick: func(n *bhv.Node, dt float64) bhv.Status {
+				position, ok := world.Components.Positions.Get(entity)
+				if !ok {
+					return bhv.StatusFailure
+				}
+				spawner, ok := world.Components.Spawners.Get(entity)
+				if !ok {
+					return bhv.StatusFailure
+				}
+				tank, err := world.Spawn(
+					spawner.Prefab,
+					position.X,
+					position.Y,
+					position.Direction,
+				)
+				if err != nil {
+					return bhv.StatusFailure
+				}
+				world.inheritTeam(tank, entity)
+				Publish(world.Events, TankSpawned{Tank: tank, Shelter: entity})
+				return bhv.StatusSuccess
+			}},
 		),
-	}
+	)
 }
 
-func SpawnTank(world *World, x, y, direction float64) {
-	entity := world.AddEntity(Tank)
+func SpawnTank(world *World, x, y, direction float64) Entity {
+	return world.mustSpawn(PrefabTank, x, y, direction)
+}
 
-	world.Components.AutoMove[entity] = &AutoMove{}
-	world.Components.Healths[entity] = &Health{Ages: true, TTL: 30, Decays: true, DecayTTL: 15}
-	world.Components.Positions[entity] = &physics.Position{X: x, Y: y, Direction: direction}
-	world.Components.BoundingBoxes[entity] = &physics.Rectangle{W: 30, H: 30}
-	world.Components.Sensings[entity] = NewSensing().SetRange(Player, 150)
-	world.Components.Velocities[entity] = &Velocity{Current: 30, AngularMax: physics.Deg180}
-	world.Components.Behaviors[entity] = &Behavior{
-		Tree: bhv.NewTree(
-			bhv.SelectorNode(
-				deadTankBehavior(world, entity),
-				tankAvoidWorldBoundariesBehavior(world, entity),
-				tankChasePlayerBehavior(world, entity),
-				tankMoveRandomlyBehavior(world, entity),
-			),
+func tankBehavior(world *World, entity Entity) *bhv.Tree {
+	return bhv.NewTree(
+		bhv.SelectorNode(
+			deadTankBehavior(world, entity),
+			tankAvoidWorldBoundariesBehavior(world, entity),
+			tankChasePlayerBehavior(world, entity),
+			tankMoveRandomlyBehavior(world, entity),
 		),
-	}
+	)
 }
 
 func deadTankBehavior(world *World, entity Entity) *bhv.Node {
 	return &bhv.Node{
 		OnTick: func(n *bhv.Node, dt float64) bhv.Status {
-			if world.Components.Healths[entity].Dead {
+			health, ok := world.Components.Healths.Get(entity)
+			if !ok || health.Dead {
 				return bhv.StatusSuccess
 			}
 			return bhv.StatusFailure
@@ -61,8 +80,8 @@ func deadTankBehavior(world *World, entity Entity) *bhv.Node {
 
 func tankAvoidWorldBoundariesBehavior(world *World, entity Entity) *bhv.Node {
 	return &bhv.Node{OnTick: func(n *bhv.Node, dt float64) bhv.Status {
-		autoMove := world.Components.AutoMove[entity]
-		pos := world.Components.Positions[entity]
+		autoMove, _ := world.Components.AutoMove.Get(entity)
+		pos, _ := world.Components.Positions.Get(entity)
 		if pos.X < 50 {
 			if pos.Y < 50 {
 				autoMove.SetTargetDirection(physics.Deg135)
@@ -94,17 +113,16 @@ func tankAvoidWorldBoundariesBehavior(world *World, entity Entity) *bhv.Node {
 func tankChasePlayerBehavior(world *World, entity Entity) *bhv.Node {
 	return bhv.SelectorNode(
 		&bhv.Node{OnTick: func(n *bhv.Node, dt float64) bhv.Status {
-			sensings := world.Components.Sensings[entity]
+			sensings, _ := world.Components.Sensings.Get(entity)
 			for _, other := range sensings.SensedEntities {
 				if other.Type != Player {
 					continue
 				}
-				pHealth := world.Components.Healths[other.Entity]
-				if pHealth.Dead {
+				if IsEntityDead(world, other.Entity) {
 					continue
 				}
-				position := world.Components.Positions[entity]
-				autoMove := world.Components.AutoMove[entity]
+				position, _ := world.Components.Positions.Get(entity)
+				autoMove, _ := world.Components.AutoMove.Get(entity)
 				autoMove.SetTargetDirection(physics.DirectionTo(position, other.Position))
 				return bhv.StatusSuccess
 			}
@@ -115,12 +133,12 @@ func tankChasePlayerBehavior(world *World, entity Entity) *bhv.Node {
 
 func tankMoveRandomlyBehavior(world *World, entity Entity) *bhv.Node {
 	return bhv.WaitNode(
-		&bhv.WaitState{TimeToWaitFn: frandomF(2, 4), InitialWait: 5},
+		&bhv.WaitState{TimeToWaitFn: world.frandomF(2, 4), InitialWait: 5},
 		&bhv.Node{
 			OnTick: func(n *bhv.Node, dt float64) bhv.Status {
-				position := world.Components.Positions[entity]
-				autoMove := world.Components.AutoMove[entity]
-				autoMove.SetTargetDirection(position.Direction + frandom(-physics.Deg45, physics.Deg45))
+				position, _ := world.Components.Positions.Get(entity)
+				autoMove, _ := world.Components.AutoMove.Get(entity)
+				autoMove.SetTargetDirection(position.Direction + world.frandom(-physics.Deg45, physics.Deg45))
 				return bhv.StatusSuccess
 			}},
 	)
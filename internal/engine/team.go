@@ -0,0 +1,46 @@
+package engine
+
+// hostile reports whether a and b are on different teams. Entities without a team are hostile to everyone.
+func hostile(components *ComponentStorage, a, b Entity) bool {
+	teamA, okA := components.Teams.Get(a)
+	teamB, okB := components.Teams.Get(b)
+	return !okA || !okB || teamA.Name != teamB.Name
+}
+
+// Hostile reports whether a and b are enemies.
+func (w *World) Hostile(a, b Entity) bool {
+	return hostile(w.Components, a, b)
+}
+
+// CanHarm reports whether attacker may kill victim. Teammates may only harm each other
+// if friendly fire is on.
+func (w *World) CanHarm(attacker, victim Entity) bool {
+	return w.friendlyFire || w.Hostile(attacker, victim)
+}
+
+// inheritTeam puts entity on the team of source, e.g. a bullet on the team of its shooter.
+func (w *World) inheritTeam(entity, source Entity) {
+	if team, ok := w.Components.Teams.Get(source); ok {
+		w.Components.Teams.Add(entity, &Team{Name: team.Name})
+	}
+}
+
+// joinTeam puts a new player on the player team with the fewest living players.
+func (w *World) joinTeam(player Entity) {
+	if len(w.teams) == 0 {
+		return
+	}
+	counts := make(map[string]int, len(w.teams))
+	w.Components.Teams.Each(func(entity Entity, team *Team) {
+		if w.EntityType(entity) == Player && !IsEntityDead(w, entity) {
+			counts[team.Name]++
+		}
+	})
+	best := w.teams[0]
+	for _, name := range w.teams[1:] {
+		if counts[name] < counts[best] {
+			best = name
+		}
+	}
+	w.Components.Teams.Add(player, &Team{Name: best})
+}
@@ -0,0 +1,134 @@
+package engine
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCanHarm(t *testing.T) {
+	tests := []struct {
+		name         string
+		a, b         string
+		friendlyFire bool
+		expect       bool
+	}{
+		{"teammates", "red", "red", false, false},
+		{"teammates with friendly fire", "red", "red", true, true},
+		{"enemies", "red", "blue", false, true},
+		{"enemies with friendly fire", "red", "blue", true, true},
+		{"teamless attacker", "", "red", false, true},
+		{"teamless victim", "red", "", false, true},
+		{"both teamless", "", "", false, true},
+	}
+	for _, tt := range tests {
+		w := NewWorld(1000, 600, 1)
+		w.friendlyFire = tt.friendlyFire
+		a, b := w.AddEntity(Tank), w.AddEntity(Player)
+		if tt.a != "" {
+			w.Components.Teams.Add(a, &Team{Name: tt.a})
+		}
+		if tt.b != "" {
+			w.Components.Teams.Add(b, &Team{Name: tt.b})
+		}
+		if got := w.CanHarm(a, b); got != tt.expect {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.expect)
+		}
+	}
+}
+
+func TestBulletsAndTanksInheritTeam(t *testing.T) {
+	e := New(1000, 600, WithSeed(1))
+	if err := e.World.Schedule(); err != nil {
+		t.Fatal(err)
+	}
+	w := e.World
+	tower := w.mustSpawn(PrefabTower, 100, 100, 0)
+	w.Components.Teams.Add(tower, &Team{Name: "red"})
+	bullet := SpawnBullet(w, tower, 100, 100, 0)
+	if team, ok := w.Components.Teams.Get(bullet); !ok || team.Name != "red" {
+		t.Error("expected the bullet to join the team of its shooter")
+	}
+	if bullet := SpawnBullet(w, w.AddEntity(Tower), 100, 100, 0); w.Components.Teams.Has(bullet) {
+		t.Error("expected the bullet of a teamless shooter to be teamless")
+	}
+
+	shelter := w.mustSpawn(PrefabTankShelter, 500, 300, 0)
+	w.Components.Teams.Add(shelter, &Team{Name: "blue"})
+	var tanks []Entity
+	Subscribe(w.Events, func(event TankSpawned) {
+		tanks = append(tanks, event.Tank)
+	})
+	e.Step(5 * DefaultTickRate)
+	if len(tanks) == 0 {
+		t.Fatal("expected the shelter to spawn a tank")
+	}
+	for _, tank := range tanks {
+		if team, ok := w.Components.Teams.Get(tank); !ok || team.Name != "blue" {
+			t.Error("expected the tank to join the team of its shelter")
+		}
+	}
+}
+
+func TestHostileOnlySensing(t *testing.T) {
+	w := NewWorld(1000, 600, 1)
+	tank := w.mustSpawn(PrefabTank, 500, 300, 0)
+	w.Components.Teams.Add(tank, &Team{Name: "red"})
+	teammate := w.mustSpawn(PrefabPlayer, 520, 300, 0)
+	w.Components.Teams.Add(teammate, &Team{Name: "red"})
+	enemy := w.mustSpawn(PrefabPlayer, 480, 300, 0)
+	w.Components.Teams.Add(enemy, &Team{Name: "blue"})
+	teamless := w.mustSpawn(PrefabPlayer, 500, 320, 0)
+
+	sense := func() []Entity {
+		NewSensingSystem(w).Update(nil, w.Components, 0)
+		sensing, _ := w.Components.Sensings.Get(tank)
+		var sensed []Entity
+		for _, other := range sensing.SensedEntities {
+			sensed = append(sensed, other.Entity)
+		}
+		slices.Sort(sensed)
+		return sensed
+	}
+	if got := sense(); !slices.Equal(got, []Entity{enemy, teamless}) {
+		t.Errorf("expected to sense the enemy and the teamless player, got %v", got)
+	}
+	sensing, _ := w.Components.Sensings.Get(tank)
+	sensing.HostileOnly = false
+	if got := sense(); !slices.Equal(got, []Entity{teammate, enemy, teamless}) {
+		t.Errorf("expected to sense every player, got %v", got)
+	}
+}
+
+func TestJoinTeamBalancesLivingPlayers(t *testing.T) {
+	e := New(1000, 600, WithSeed(1), WithTeams("red", "blue"))
+	if err := e.World.Schedule(); err != nil {
+		t.Fatal(err)
+	}
+	join := func() (Entity, string) {
+		player, _ := e.PlayerWithId(e.SpawnPlayer())
+		team, _ := e.World.Components.Teams.Get(player)
+		return player, team.Name
+	}
+	var teams []string
+	var blue Entity
+	for range 3 {
+		player, team := join()
+		teams = append(teams, team)
+		if team == "blue" {
+			blue = player
+		}
+	}
+	if !slices.Equal(teams, []string{"red", "blue", "red"}) {
+		t.Fatalf("expected players to alternate teams, got %v", teams)
+	}
+	e.World.Kill(blue, NoEntity)
+	// The dead player doesn't count, so blue has fewer players until it has two living ones.
+	teams = teams[:0]
+	for range 3 {
+		_, team := join()
+		teams = append(teams, team)
+	}
+	if !slices.Equal(teams, []string{"blue", "blue", "red"}) {
+		t.Errorf("expected players to fill up the team with the dead player, got %v", teams)
+	}
+}
@@ -1,56 +1,62 @@
 package engine
 
 import (
+	"encoding/json"
+
 	"cfichtmueller.com/htmx-game/internal/engine/bhv"
 	"cfichtmueller.com/htmx-game/internal/engine/physics"
 )
 
-func SpawnTower(world *World, x, y float64) {
-	entity := world.AddEntity(Tower)
+const PrefabTower = "tower"
 
-	world.Components.AutoMove[entity] = &AutoMove{}
-	world.Components.Healths[entity] = &Health{Decays: true, DecayTTL: 30}
-	world.Components.Positions[entity] = &physics.Position{X: x, Y: y}
-	world.Components.BoundingBoxes[entity] = &physics.Rectangle{W: 30, H: 30}
-	world.Components.Velocities[entity] = &Velocity{AngularMax: physics.Deg90}
-	world.Components.Behaviors[entity] = &Behavior{
-		Tree: towerBehavior(world, entity),
-	}
+func SpawnTower(world *World, x, y float64) Entity {
+	return world.mustSpawn(PrefabTower, x, y, 0)
 }
 
+// towerBehavior reads the config whenever it is used, so config changes apply to existing towers
+// as well, and towers restored from a snapshot behave like the ones that were saved.
 func towerBehavior(world *World, entity Entity) *bhv.Tree {
 	return bhv.NewTree(
 		bhv.SequenceNode(
 			&bhv.Node{
 				OnTick: func(n *bhv.Node, dt float64) bhv.Status {
-					health := world.Components.Healths[entity]
-					if health.Dead {
+					health, ok := world.Components.Healths.Get(entity)
+					if !ok || health.Dead {
 						return bhv.StatusFailure
 					}
 					return bhv.StatusSuccess
 				},
 			},
 			bhv.WaitNode(
-				&bhv.WaitState{TimeToWaitFn: frandomF(5, 10)},
+				&bhv.WaitState{TimeToWaitFn: func() float64 {
+					interval := world.config.Tower.AimInterval
+					return world.frandom(interval.Min, interval.Max)
+				}},
 				AimBehavior(
 					&AimState{
 						World:             world,
 						Entity:            entity,
-						TargetDirectionFn: frandomF(physics.Deg0, physics.Deg360),
+						TargetDirectionFn: world.frandomF(physics.Deg0, physics.Deg360),
 					},
 					bhv.BurstBehavior(
-						&bhv.BurstState{Interval: 0.3, BurstSizeFn: irandomF(3, 6)},
+						&bhv.BurstState{
+							IntervalFn: func() float64 {
+								return world.config.Tower.BurstInterval
+							},
+							BurstSizeFn: func() int {
+								size := world.config.Tower.BurstSize
+								return world.irandom(size.Min, size.Max)
+							},
+						},
 						bhv.ActionNode(func(n *bhv.Node, dt float64) bhv.Status {
-							towerPos := world.Components.Positions[entity]
-							towerBb := world.Components.BoundingBoxes[entity]
-							spread := frandom(-0.02, 0.02)
+							towerPos, _ := world.Components.Positions.Get(entity)
+							spread := world.frandom(-world.config.Tower.Spread, world.config.Tower.Spread)
 							SpawnBullet(
 								world,
-								towerPos.X+towerBb.W/2,
-								towerPos.Y+towerBb.H/2,
+								entity,
+								towerPos.X,
+								towerPos.Y,
 								towerPos.Direction+spread,
-								70,
-								10,
 							)
 							return bhv.StatusSuccess
 						}),
@@ -69,13 +75,32 @@ type AimState struct {
 	hasAimed          bool
 }
 
+type aimSnapshot struct {
+	IsAiming bool `json:"isAiming"`
+	HasAimed bool `json:"hasAimed"`
+}
+
+func (s *AimState) SaveState() (json.RawMessage, error) {
+	return json.Marshal(aimSnapshot{IsAiming: s.isAiming, HasAimed: s.hasAimed})
+}
+
+func (s *AimState) LoadState(data json.RawMessage) error {
+	var snapshot aimSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return err
+	}
+	s.isAiming = snapshot.IsAiming
+	s.hasAimed = snapshot.HasAimed
+	return nil
+}
+
 func AimBehavior(s *AimState, child *bhv.Node) *bhv.Node {
 	return &bhv.Node{
 		Data:     s,
 		Children: []*bhv.Node{child},
 		OnTick: func(n *bhv.Node, dt float64) bhv.Status {
 			d := n.Data.(*AimState)
-			autoMove := d.World.Components.AutoMove[d.Entity]
+			autoMove, _ := d.World.Components.AutoMove.Get(d.Entity)
 
 			if !d.isAiming && !d.hasAimed {
 				autoMove.SetTargetDirection(d.TargetDirectionFn())
@@ -1,32 +1,71 @@
 package engine
 
-func SetEntityDirection(world *World, entity Entity, d float64) {
-	world.Components.Positions[entity].Direction = d
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrEntityNotAlive = errors.New("entity is not alive")
+
+func getComponent[T any](world *World, store *Store[T], entity Entity) (*T, error) {
+	if !world.Alive(entity) {
+		return nil, ErrEntityNotAlive
+	}
+	component, ok := store.Get(entity)
+	if !ok {
+		return nil, fmt.Errorf("entity %d has no %s", entity, store.Name())
+	}
+	return component, nil
 }
 
-func KillEntity(world *World, entity Entity) {
-	world.Components.Healths[entity].Dead = true
+func SetEntityDirection(world *World, entity Entity, d float64) error {
+	position, err := getComponent(world, world.Components.Positions, entity)
+	if err != nil {
+		return err
+	}
+	if velocity, ok := world.Components.Velocities.Get(entity); ok {
+		velocity.Turn(d - position.Direction)
+	}
+	position.Direction = d
+	return nil
 }
 
+func KillEntity(world *World, entity Entity) error {
+	if _, err := getComponent(world, world.Components.Healths, entity); err != nil {
+		return err
+	}
+	world.Kill(entity, NoEntity)
+	return nil
+}
+
+// IsEntityDead reports whether the entity is dead or already removed.
 func IsEntityDead(world *World, entity Entity) bool {
-	return world.Components.Healths[entity].Dead
+	health, err := getComponent(world, world.Components.Healths, entity)
+	if err != nil {
+		return !world.Alive(entity)
+	}
+	return health.Dead
 }
 
-func SetEntityVelocity(world *World, entity Entity, v float64) {
-	velocity := world.Components.Velocities[entity]
-	velocity.Current = velocity.Max * v
+// SetEntityVelocity makes the entity move forward with the fraction v of its max speed.
+func SetEntityVelocity(world *World, entity Entity, v float64) error {
+	velocity, err := getComponent(world, world.Components.Velocities, entity)
+	if err != nil {
+		return err
+	}
+	return world.SetVelocity(entity, velocity.Max*v)
 }
 
 type Placement struct {
 	x, y int
 }
 
-func PlaceInRaster(count, width, height int, generator func(x, y int)) {
+func (w *World) PlaceInRaster(count, width, height int, generator func(x, y int)) {
 	placements := make([]Placement, count)
 	placed := 0
 	for placed < count {
-		x := irandom(0, width)
-		y := irandom(0, height)
+		x := w.irandom(0, width)
+		y := w.irandom(0, height)
 
 		conflict := false
 		for _, placement := range placements[:placed] {
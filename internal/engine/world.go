@@ -1,64 +1,199 @@
 package engine
 
+import (
+	"math/rand/v2"
+	"time"
+
+	"cfichtmueller.com/htmx-game/internal/engine/physics"
+)
+
 type World struct {
-	nextEntity       int64
+	generations      []uint32
+	freeIndices      []uint32
 	Entities         []Entity
 	entitiesToRemove map[Entity]bool
 	Components       *ComponentStorage
-	systems          []System
+	prefabs          map[string]*Prefab
+	prefabFields     map[string]map[string]bool
+	config           Config
+	friendlyFire     bool
+	teams            []string
+	Events           *EventBus
+	systems          []scheduledSystem
+	order            []System
+	profile          *worldProfile
+	spatialIndex     *spatialIndex
+	spatialValid     bool
 	Width            float64
 	Height           float64
+	Seed             uint64
+	source           *rand.PCG
+	rand             *rand.Rand
 }
 
-func NewWorld(width, height float64) *World {
-	return &World{
+// NewWorld creates an empty world. All randomness in the world is drawn from a source seeded with seed.
+func NewWorld(width, height float64, seed uint64) *World {
+	source := rand.NewPCG(seed, seed)
+	world := &World{
+		generations:      make([]uint32, 0),
+		freeIndices:      make([]uint32, 0),
 		Entities:         make([]Entity, 0),
 		entitiesToRemove: make(map[Entity]bool),
 		Components:       NewComponentStorage(),
-		systems:          make([]System, 0),
+		prefabFields:     make(map[string]map[string]bool),
+		Events:           NewEventBus(),
+		systems:          make([]scheduledSystem, 0),
+		profile:          newWorldProfile(),
+		spatialIndex:     newSpatialIndex(),
 		Width:            width,
 		Height:           height,
+		Seed:             seed,
+		source:           source,
+		rand:             rand.New(source),
+		config:           DefaultConfig(),
 	}
+	if err := world.AddPrefabs(DefaultPrefabs()); err != nil {
+		panic(err)
+	}
+	return world
 }
 
 func (w *World) AddEntity(entityType EntityType) Entity {
-	entity := Entity(w.nextEntity)
-	w.nextEntity++
+	var index uint32
+	if len(w.freeIndices) > 0 {
+		index = w.freeIndices[0]
+		w.freeIndices = w.freeIndices[1:]
+	} else {
+		index = uint32(len(w.generations))
+		w.generations = append(w.generations, 1)
+	}
+	entity := newEntity(index, w.generations[index])
 	w.Entities = append(w.Entities, entity)
-	w.Components.EntityTypes[entity] = &EntityTypeComponent{
+	w.spatialValid = false
+	w.Components.EntityTypes.Add(entity, &EntityTypeComponent{
 		Type: entityType,
-	}
+	})
 	return entity
 }
 
+// RemoveEntity marks the entity for removal at the end of the current update.
 func (w *World) RemoveEntity(entity Entity) {
+	if !w.Alive(entity) || w.entitiesToRemove[entity] {
+		return
+	}
 	w.entitiesToRemove[entity] = true
+	for _, child := range w.Children(entity) {
+		parent, _ := w.Components.Parents.Get(child)
+		if parent.Policy != DetachChildren {
+			w.RemoveEntity(child)
+		}
+	}
+}
+
+// Kill marks the entity as dead and publishes EntityDied. It returns false if the entity
+// can't die or is already dead. killer may be NoEntity.
+func (w *World) Kill(entity, killer Entity) bool {
+	health, err := getComponent(w, w.Components.Healths, entity)
+	if err != nil || health.Dead {
+		return false
+	}
+	health.Dead = true
+
+	Publish(w.Events, EntityDied{
+		Entity:     entity,
+		Type:       w.EntityType(entity),
+		Killer:     killer,
+		KillerType: w.EntityType(killer),
+	})
+	return true
+}
+
+// EntityType returns the type the entity was created with, or NoEntityType if it doesn't exist.
+func (w *World) EntityType(entity Entity) EntityType {
+	t, ok := w.Components.EntityTypes.Get(entity)
+	if !ok {
+		return NoEntityType
+	}
+	return t.Type
+}
+
+// Alive reports whether entity refers to an entity that hasn't been removed yet.
+func (w *World) Alive(entity Entity) bool {
+	index := entity.Index()
+	return int(index) < len(w.generations) && w.generations[index] == entity.Generation()
 }
 
-func (w *World) AddSystem(sytem System) {
-	w.systems = append(w.systems, sytem)
+// AddSystem adds a system that runs according to schedule. The order of all systems is
+// computed again before the next update.
+func (w *World) AddSystem(system System, schedule Schedule) {
+	w.systems = append(w.systems, scheduledSystem{schedule: schedule, system: system})
+	w.order = nil
 }
 
+// Schedule computes the order of the systems. It fails if their constraints contradict each other.
+func (w *World) Schedule() error {
+	if w.order != nil {
+		return nil
+	}
+	return w.schedule()
+}
+
+// Update runs all systems once. It panics if the systems can't be scheduled.
 func (w *World) Update(dt float64) {
-	for _, system := range w.systems {
+	if err := w.Schedule(); err != nil {
+		panic(err)
+	}
+	start := time.Now()
+	collisions := 0
+	for _, system := range w.order {
+		systemStart := time.Now()
 		system.Update(w.Entities, w.Components, dt)
+		// Systems may move entities, the spatial index is rebuilt when it is queried next.
+		w.spatialValid = false
+		w.profile.system(system).add(int64(time.Since(systemStart)))
+		if detector, ok := system.(interface{ Collisions() []Collision }); ok {
+			collisions += len(detector.Collisions())
+		}
 	}
+	w.profile.entities.add(int64(len(w.Entities)))
+	w.profile.collisions.add(int64(collisions))
 	w.cleanupEntities()
+	w.profile.update.add(int64(time.Since(start)))
 }
 
 func (w *World) cleanupEntities() {
-	for entity := range w.entitiesToRemove {
-		w.Components.RemoveEntity(entity)
-		for i, e := range w.Entities {
-			if e == entity {
-				w.Entities = append(w.Entities[:i], w.Entities[i+1:]...)
-				break
-			}
+	if len(w.entitiesToRemove) == 0 {
+		return
+	}
+	remaining := w.Entities[:0]
+	for _, entity := range w.Entities {
+		if !w.entitiesToRemove[entity] {
+			remaining = append(remaining, entity)
+			continue
+		}
+		Publish(w.Events, EntityRemoved{Entity: entity, Type: w.EntityType(entity)})
+		for _, child := range w.Children(entity) {
+			w.Components.Parents.Remove(child)
 		}
+		w.Components.RemoveEntity(entity)
+		w.generations[entity.Index()]++
+		w.freeIndices = append(w.freeIndices, entity.Index())
 	}
+	w.Entities = remaining
 	w.entitiesToRemove = make(map[Entity]bool)
+	w.spatialValid = false
 }
 
-func (w *World) SetVelocity(entity Entity, v float64) {
-	w.Components.Velocities[entity].Current = v
+// SetVelocity makes the entity move forward with speed v.
+func (w *World) SetVelocity(entity Entity, v float64) error {
+	position, err := getComponent(w, w.Components.Positions, entity)
+	if err != nil {
+		return err
+	}
+	velocity, err := getComponent(w, w.Components.Velocities, entity)
+	if err != nil {
+		return err
+	}
+	velocity.Linear = physics.FromAngle(position.Direction).Scale(v)
+	return nil
 }
@@ -0,0 +1,218 @@
+package room
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"cfichtmueller.com/htmx-game/internal/engine"
+)
+
+const MaxTeams = 8
+
+type Settings struct {
+	Name         string
+	Width        float64
+	Height       float64
+	FriendlyFire bool
+	// Teams that players are split into. Without teams, players fight each other.
+	Teams []string
+}
+
+func (s Settings) Validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if s.Width < 200 || s.Width > 5000 {
+		return fmt.Errorf("width must be between 200 and 5000")
+	}
+	if s.Height < 200 || s.Height > 5000 {
+		return fmt.Errorf("height must be between 200 and 5000")
+	}
+	if len(s.Teams) > MaxTeams {
+		return fmt.Errorf("at most %d teams are allowed", MaxTeams)
+	}
+	seen := make(map[string]bool, len(s.Teams))
+	for _, team := range s.Teams {
+		if team == "" {
+			return fmt.Errorf("team names must not be empty")
+		}
+		if seen[team] {
+			return fmt.Errorf("duplicate team %s", team)
+		}
+		seen[team] = true
+	}
+	return nil
+}
+
+type Room struct {
+	ID       string
+	Settings Settings
+	Engine   *engine.Engine
+	Created  time.Time
+}
+
+type Manager struct {
+	mu     sync.Mutex
+	rooms  map[string]*Room
+	config *engine.Config
+}
+
+func NewManager() *Manager {
+	return &Manager{
+		rooms: make(map[string]*Room),
+	}
+}
+
+// Create builds a new engine for the room. The engine isn't started.
+func (m *Manager) Create(settings Settings, opts ...engine.Option) (*Room, error) {
+	if err := settings.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid room settings: %v", err)
+	}
+	id, err := randomId()
+	if err != nil {
+		return nil, err
+	}
+	opts = append([]engine.Option{
+		engine.WithFriendlyFire(settings.FriendlyFire),
+		engine.WithTeams(settings.Teams...),
+	}, opts...)
+	m.mu.Lock()
+	if m.config != nil {
+		opts = append([]engine.Option{engine.WithConfig(*m.config)}, opts...)
+	}
+	m.mu.Unlock()
+	room := &Room{
+		ID:       id,
+		Settings: settings,
+		Engine:   engine.New(settings.Width, settings.Height, opts...),
+		Created:  time.Now(),
+	}
+
+	m.mu.Lock()
+	m.rooms[id] = room
+	m.mu.Unlock()
+	return room, nil
+}
+
+// CreateReplay builds an engine that replays the recording. The engine isn't started.
+func (m *Manager) CreateReplay(name string, recording *engine.Recording) (*Room, error) {
+	if name == "" {
+		name = "Replay"
+	}
+	id, err := randomId()
+	if err != nil {
+		return nil, err
+	}
+	e, err := engine.NewReplay(recording)
+	if err != nil {
+		return nil, fmt.Errorf("unable to replay recording: %v", err)
+	}
+	room := &Room{
+		ID:       id,
+		Settings: Settings{Name: name, Width: recording.Width, Height: recording.Height},
+		Engine:   e,
+		Created:  time.Now(),
+	}
+
+	m.mu.Lock()
+	m.rooms[id] = room
+	m.mu.Unlock()
+	return room, nil
+}
+
+func (m *Manager) Get(id string) (*Room, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	room, ok := m.rooms[id]
+	return room, ok
+}
+
+// List returns all rooms, oldest first.
+func (m *Manager) List() []*Room {
+	m.mu.Lock()
+	rooms := make([]*Room, 0, len(m.rooms))
+	for _, room := range m.rooms {
+		rooms = append(rooms, room)
+	}
+	m.mu.Unlock()
+
+	sort.Slice(rooms, func(i, j int) bool {
+		return rooms[i].Created.Before(rooms[j].Created)
+	})
+	return rooms
+}
+
+// Match returns the room with the fewest players. Replays are never matched.
+func (m *Manager) Match() (*Room, bool) {
+	var match *Room
+	matchPlayers := 0
+	for _, room := range m.List() {
+		room.Engine.Lock()
+		players := room.Engine.PlayerCount()
+		replaying := room.Engine.Replaying()
+		room.Engine.Unlock()
+		if replaying {
+			continue
+		}
+		if match == nil || players < matchPlayers {
+			match = room
+			matchPlayers = players
+		}
+	}
+	return match, match != nil
+}
+
+// Remove stops the engine of the room and forgets about it.
+func (m *Manager) Remove(id string) bool {
+	m.mu.Lock()
+	room, ok := m.rooms[id]
+	delete(m.rooms, id)
+	m.mu.Unlock()
+
+	if ok {
+		room.Engine.Stop()
+	}
+	return ok
+}
+
+// SetConfig applies the config to all rooms except replays and to rooms created afterwards.
+func (m *Manager) SetConfig(config engine.Config) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.config = &config
+	m.mu.Unlock()
+
+	for _, room := range m.List() {
+		room.Engine.Lock()
+		var err error
+		if !room.Engine.Replaying() {
+			err = room.Engine.SetConfig(config)
+		}
+		room.Engine.Unlock()
+		if err != nil {
+			return fmt.Errorf("unable to configure room %s: %v", room.ID, err)
+		}
+	}
+	return nil
+}
+
+// StopAll stops the engines of all rooms. The rooms are kept, e.g. to save snapshots.
+func (m *Manager) StopAll() {
+	for _, room := range m.List() {
+		room.Engine.Stop()
+	}
+}
+
+func randomId() (string, error) {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("unable to create room id: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,174 @@
+package sim
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"text/tabwriter"
+
+	"cfichtmueller.com/htmx-game/internal/engine"
+)
+
+type Config struct {
+	Width        float64
+	Height       float64
+	Players      int
+	Duration     float64
+	RespawnDelay float64
+	Seed         uint64
+	TickRate     int
+}
+
+// Stats of a simulation. AverageSurvival counts players that are alive at the end with the time
+// they survived so far, so long lives aren't left out.
+type Stats struct {
+	Seed              uint64
+	Duration          float64
+	Steps             int
+	Players           int
+	Deaths            int
+	Survivors         int
+	AverageSurvival   float64
+	KilledByTanks     int
+	KilledByTowers    int
+	OtherDeaths       int
+	TowersDestroyed   int
+	PowerUpsCollected int
+	PeakEntities      int
+}
+
+// bot is a player that walks in a random direction and changes it every few seconds.
+type bot struct {
+	id       string
+	spawned  float64
+	died     float64
+	dead     bool
+	nextTurn float64
+}
+
+// Run simulates the world with random-walk players for config.Duration seconds of game time.
+// Dead players respawn after config.RespawnDelay seconds. Players send their input like remote
+// players do, it applies with the next step.
+func Run(config Config, opts ...engine.Option) (Stats, error) {
+	opts = append(opts, engine.WithSeed(config.Seed), engine.WithTickRate(config.TickRate))
+	e := engine.New(config.Width, config.Height, opts...)
+	if err := e.Init(); err != nil {
+		return Stats{}, fmt.Errorf("unable to initialize engine: %v", err)
+	}
+
+	stats := Stats{Seed: config.Seed, Players: config.Players}
+	world := e.World
+	shooters := make(map[engine.Entity]engine.EntityType)
+	engine.Subscribe(world.Events, func(event engine.BulletFired) {
+		shooters[event.Bullet] = world.EntityType(event.Shooter)
+	})
+	engine.Subscribe(world.Events, func(event engine.EntityRemoved) {
+		delete(shooters, event.Entity)
+	})
+	engine.Subscribe(world.Events, func(event engine.PowerUpCollected) {
+		stats.PowerUpsCollected++
+	})
+	engine.Subscribe(world.Events, func(event engine.EntityDied) {
+		switch event.Type {
+		case engine.Tower:
+			stats.TowersDestroyed++
+		case engine.Player:
+			switch {
+			case event.KillerType == engine.Tank:
+				stats.KilledByTanks++
+			case event.KillerType == engine.Bullet && shooters[event.Killer] == engine.Tower:
+				stats.KilledByTowers++
+			default:
+				stats.OtherDeaths++
+			}
+		}
+	})
+
+	rng := rand.New(rand.NewPCG(config.Seed, config.Seed+1))
+	bots := make([]*bot, config.Players)
+	for i := range bots {
+		bots[i] = &bot{id: e.SpawnPlayer()}
+	}
+
+	dt := 1 / float64(e.TickRate())
+	stats.Steps = int(config.Duration / dt)
+	survival := 0.0
+	for step := 0; step < stats.Steps; step++ {
+		now := float64(step) * dt
+		e.Lock()
+		for _, b := range bots {
+			if err := b.play(e, &stats, config, rng, now, &survival); err != nil {
+				e.Unlock()
+				return stats, err
+			}
+		}
+		e.Unlock()
+		e.Step(1)
+		stats.PeakEntities = max(stats.PeakEntities, len(world.Entities))
+	}
+
+	stats.Duration = float64(stats.Steps) * dt
+	for _, b := range bots {
+		player, _ := e.PlayerWithId(b.id)
+		if !b.dead && !engine.IsEntityDead(world, player) {
+			stats.Survivors++
+			survival += stats.Duration - b.spawned
+		}
+	}
+	if lives := stats.Deaths + stats.Survivors; lives > 0 {
+		stats.AverageSurvival = survival / float64(lives)
+	}
+	return stats, nil
+}
+
+// play queues the input of the bot for the next step. The caller must hold the lock.
+func (b *bot) play(e *engine.Engine, stats *Stats, config Config, rng *rand.Rand, now float64, survival *float64) error {
+	player, _ := e.PlayerWithId(b.id)
+	if engine.IsEntityDead(e.World, player) {
+		if !b.dead {
+			stats.Deaths++
+			*survival += now - b.spawned
+			b.dead = true
+			b.died = now
+		}
+		if now-b.died < config.RespawnDelay {
+			return nil
+		}
+		if err := e.Respawn(b.id); err != nil {
+			return fmt.Errorf("unable to respawn %s: %v", b.id, err)
+		}
+		b.dead = false
+		b.spawned = now
+		b.nextTurn = now
+		return nil
+	}
+	if now < b.nextTurn {
+		return nil
+	}
+	if err := e.Enqueue(b.id, engine.Command{M: engine.CommandSetRotation, V: rng.Float64() * 2 * math.Pi}); err != nil {
+		return fmt.Errorf("unable to turn %s: %v", b.id, err)
+	}
+	if err := e.Enqueue(b.id, engine.Command{M: engine.CommandSetVelocity, V: 1}); err != nil {
+		return fmt.Errorf("unable to move %s: %v", b.id, err)
+	}
+	b.nextTurn = now + 1 + rng.Float64()*2
+	return nil
+}
+
+func (s Stats) Print(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "seed\t%d\n", s.Seed)
+	fmt.Fprintf(tw, "simulated\t%.0fs (%d steps)\n", s.Duration, s.Steps)
+	fmt.Fprintf(tw, "players\t%d\n", s.Players)
+	fmt.Fprintf(tw, "player deaths\t%d\n", s.Deaths)
+	fmt.Fprintf(tw, "alive at the end\t%d\n", s.Survivors)
+	fmt.Fprintf(tw, "average survival\t%.1fs\n", s.AverageSurvival)
+	fmt.Fprintf(tw, "killed by tanks\t%d\n", s.KilledByTanks)
+	fmt.Fprintf(tw, "killed by towers\t%d\n", s.KilledByTowers)
+	fmt.Fprintf(tw, "other deaths\t%d\n", s.OtherDeaths)
+	fmt.Fprintf(tw, "towers destroyed\t%d\n", s.TowersDestroyed)
+	fmt.Fprintf(tw, "power-ups collected\t%d\n", s.PowerUpsCollected)
+	fmt.Fprintf(tw, "peak entities\t%d\n", s.PeakEntities)
+	return tw.Flush()
+}
@@ -2,16 +2,28 @@
 
   let vw = document.getElementById("vw");
   let vh = document.getElementById("vh");
-
-  let pid = document.querySelector("[name=playerId]").value;
+  if (!vw) {
+    return;
+  }
 
   function onWindowResize() {
     vw.setAttribute("value", window.innerWidth);
     vh.setAttribute("value", window.innerHeight);  
   }
 
+  window.addEventListener("resize", onWindowResize)
+  onWindowResize()
+
+  let roomInput = document.querySelector("[name=roomId]");
+  if (!roomInput) {
+    return;
+  }
+
+  let rid = roomInput.value;
+  let pid = document.querySelector("[name=playerId]").value;
+
   function notifyPlayer(...payload) {
-    return fetch("/player/" + pid, {
+    return fetch("/room/" + rid + "/player/" + pid, {
       method: "POST",
       body: JSON.stringify({
         commands: payload,
@@ -22,8 +34,6 @@
   const setVelocity = (v) => ({ m: "setVelocity", v });
   const setRotation = (v) => ({ m: "setRotation", v });
 
-  window.addEventListener("resize", onWindowResize)
-
   document.addEventListener("keydown", function (e) {
     console.log("key down", e);
     switch (e.code) {
@@ -64,12 +74,19 @@
         dx = 1;
         break;
       case "p":
-        notifyPlayer({ m: "respawn" }).then(() => window.location.assign("/"));
+        notifyPlayer({ m: "respawn" }).then((res) => {
+          if (res.status === 409) {
+            return;
+          }
+          if (res.ok) {
+            window.location.reload();
+          } else {
+            window.location.assign("/room/" + rid);
+          }
+        });
         return;
       default:
         return;
     }
   });
-
-  onWindowResize()
 })();
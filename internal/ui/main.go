@@ -6,9 +6,11 @@ import (
 	"fmt"
 	"html/template"
 	"io"
+	"strings"
 
 	"cfichtmueller.com/htmx-game/internal/client"
 	"cfichtmueller.com/htmx-game/internal/engine"
+	"cfichtmueller.com/htmx-game/internal/room"
 )
 
 var (
@@ -58,17 +60,92 @@ type playerModel struct {
 }
 
 type indexPageModel struct {
+	RoomID string
 	Engine *engine.Engine
 	Player playerModel
 }
 
-func RenderIndexPage(w io.Writer, e *engine.Engine, p string) error {
+func RenderIndexPage(w io.Writer, roomId string, e *engine.Engine, p string) error {
 	return renderTemplate(w, "IndexPage", indexPageModel{
+		RoomID: roomId,
 		Engine: e,
 		Player: playerModel{ID: p},
 	})
 }
 
+type replayPageModel struct {
+	RoomID   string
+	Duration float64
+}
+
+func RenderReplayPage(w io.Writer, roomId string, e *engine.Engine) error {
+	return renderTemplate(w, "ReplayPage", replayPageModel{
+		RoomID:   roomId,
+		Duration: float64(e.ReplayTicks()) / float64(e.TickRate()),
+	})
+}
+
+type lobbyRoomModel struct {
+	ID           string
+	Name         string
+	Width        float64
+	Height       float64
+	Players      int
+	Teams        string
+	FriendlyFire bool
+	Replay       bool
+}
+
+type lobbyPageModel struct {
+	Rooms []lobbyRoomModel
+}
+
+func RenderLobbyPage(w io.Writer, rooms []*room.Room) error {
+	model := lobbyPageModel{Rooms: make([]lobbyRoomModel, 0, len(rooms))}
+	for _, r := range rooms {
+		r.Engine.Lock()
+		players := r.Engine.PlayerCount()
+		replay := r.Engine.Replaying()
+		r.Engine.Unlock()
+		model.Rooms = append(model.Rooms, lobbyRoomModel{
+			ID:           r.ID,
+			Name:         r.Settings.Name,
+			Width:        r.Settings.Width,
+			Height:       r.Settings.Height,
+			Players:      players,
+			Teams:        strings.Join(r.Settings.Teams, ", "),
+			FriendlyFire: r.Settings.FriendlyFire,
+			Replay:       replay,
+		})
+	}
+	return renderTemplate(w, "LobbyPage", model)
+}
+
+type debugRoomModel struct {
+	ID    string
+	Name  string
+	Stats engine.EngineStats
+}
+
+type debugEnginePageModel struct {
+	Rooms []debugRoomModel
+}
+
+func RenderDebugEnginePage(w io.Writer, rooms []*room.Room) error {
+	model := debugEnginePageModel{Rooms: make([]debugRoomModel, 0, len(rooms))}
+	for _, r := range rooms {
+		r.Engine.Lock()
+		stats := r.Engine.Stats()
+		r.Engine.Unlock()
+		model.Rooms = append(model.Rooms, debugRoomModel{
+			ID:    r.ID,
+			Name:  r.Settings.Name,
+			Stats: stats,
+		})
+	}
+	return renderTemplate(w, "DebugEnginePage", model)
+}
+
 func RenderField(w io.Writer, s *client.State) error {
 	return renderTemplate(w, "Field", s)
 }
@@ -1,21 +1,81 @@
 package main
 
 import (
+	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
+	"flag"
 	"io"
+	"io/fs"
 	"log"
+	"math/rand/v2"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"cfichtmueller.com/htmx-game/internal/client"
 	"cfichtmueller.com/htmx-game/internal/engine"
+	"cfichtmueller.com/htmx-game/internal/room"
+	"cfichtmueller.com/htmx-game/internal/sim"
 	"cfichtmueller.com/htmx-game/internal/ui"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "simulate" {
+		simulate(os.Args[2:])
+		return
+	}
+
+	snapshotPath := flag.String("snapshot", "", "restore the default room from this snapshot file if it exists and save it there on shutdown")
+	prefabsPath := flag.String("prefabs", "", "load additional entity prefabs from the .json files in this directory")
+	configPath := flag.String("config", "", "load game tuning from this file and reload it when it changes")
+	idleTimeout := flag.Duration("idle-timeout", engine.DefaultIdleTimeout, "remove players that neither sent input nor polled the field for this long")
+	adminSecret := flag.String("admin-secret", "", "let requests forwarded by a proxy pause and speed up rooms with this password, they can't without one")
+	flag.Parse()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	opts := append(prefabOptions(*prefabsPath), engine.WithIdleTimeout(*idleTimeout))
+
+	rooms := room.NewManager()
+	if *configPath != "" {
+		config, err := engine.LoadConfig(*configPath)
+		if err != nil {
+			log.Fatalf("unable to load config: %v", err)
+		}
+		if err := rooms.SetConfig(config); err != nil {
+			log.Fatalf("unable to apply config: %v", err)
+		}
+		go watchConfig(ctx, *configPath, func(config engine.Config) error {
+			return rooms.SetConfig(config)
+		})
+	}
 
-	game := engine.New(1000, 600)
+	defaultRoom, err := rooms.Create(room.Settings{Name: "Main", Width: 1000, Height: 600}, opts...)
+	if err != nil {
+		log.Fatalf("unable to create default room: %v", err)
+	}
 
-	game.Start()
+	if *snapshotPath != "" {
+		err := defaultRoom.Engine.LoadSnapshot(*snapshotPath)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.Fatalf("unable to restore snapshot: %v", err)
+		}
+		if err == nil {
+			log.Printf("restored snapshot %s", *snapshotPath)
+		}
+	}
+
+	if err := defaultRoom.Engine.Start(ctx); err != nil {
+		log.Fatalf("unable to start default room: %v", err)
+	}
 
 	http.HandleFunc("/js/{name}", func(w http.ResponseWriter, r *http.Request) {
 		name := r.PathValue("name")
@@ -34,7 +94,8 @@ func main() {
 		w.Write(ui.Css)
 	})
 
-	http.HandleFunc("/player/{id}", func(w http.ResponseWriter, r *http.Request) {
+	http.HandleFunc("/room/{room}/player/{id}", withRoom(rooms, func(w http.ResponseWriter, r *http.Request, rm *room.Room) {
+		game := rm.Engine
 		id := r.PathValue("id")
 		game.Lock()
 		defer game.Unlock()
@@ -51,7 +112,7 @@ func main() {
 				return
 			}
 
-			if !must("render index", ui.RenderIndexPage(w, game, id)) {
+			if !must("render index", ui.RenderIndexPage(w, rm.ID, game, id)) {
 				return
 			}
 			if includeShell && !must("render shell end", ui.RenderShellEnd(w)) {
@@ -75,28 +136,51 @@ func main() {
 		var input PlayerInput
 		json.Unmarshal(b, &input)
 
+		game.RecordInput(id)
+		if game.Paused() || game.Replaying() {
+			w.WriteHeader(409)
+			return
+		}
+		for _, cmd := range input.Commands {
+			if cmd.M == engine.CommandRespawn {
+				if !must("respawn player", game.Respawn(id)) {
+					w.WriteHeader(500)
+				}
+				return
+			}
+		}
+
+		if !game.World.Alive(p) {
+			w.WriteHeader(410)
+			return
+		}
 		if engine.IsEntityDead(game.World, p) {
 			return
 		}
 
 		for _, cmd := range input.Commands {
-			switch cmd.M {
-			case "setVelocity":
-				engine.SetEntityVelocity(game.World, p, cmd.V)
-			case "setRotation":
-				engine.SetEntityDirection(game.World, p, cmd.V)
-			case "respawn":
-				engine.KillEntity(game.World, p)
+			err := game.Enqueue(id, cmd)
+			if errors.Is(err, engine.ErrUnknownCommand) || errors.Is(err, engine.ErrInvalidCommand) {
+				w.WriteHeader(400)
+				return
+			}
+			if errors.Is(err, engine.ErrQueueFull) {
+				w.WriteHeader(429)
+				return
+			}
+			if !must("queue player command", err) {
+				w.WriteHeader(500)
 				return
 			}
 		}
-	})
+	}))
 
-	http.HandleFunc("/player/{id}/osd", func(w http.ResponseWriter, r *http.Request) {
+	http.HandleFunc("/room/{room}/player/{id}/osd", withRoom(rooms, func(w http.ResponseWriter, r *http.Request, rm *room.Room) {
 		if r.Method != "GET" {
 			w.WriteHeader(405)
 			return
 		}
+		game := rm.Engine
 		id := r.PathValue("id")
 		game.Lock()
 		defer game.Unlock()
@@ -106,7 +190,7 @@ func main() {
 			return
 		}
 		must("render osd", ui.RenderOsd(w, p))
-	})
+	}))
 
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path != "/" {
@@ -118,15 +202,247 @@ func main() {
 			w.WriteHeader(204)
 			return
 		}
+		w.Header().Set("Cache-Control", "no-store")
+		if !must("render shell start", ui.RenderShellStart(w)) {
+			return
+		}
+		if !must("render lobby", ui.RenderLobbyPage(w, rooms.List())) {
+			return
+		}
+		must("render shell end", ui.RenderShellEnd(w))
+	})
+
+	http.HandleFunc("/debug/engine", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			w.WriteHeader(405)
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		includeShell := r.Header.Get("Hx-Request") != "true"
+		if includeShell && !must("render shell start", ui.RenderShellStart(w)) {
+			return
+		}
+		if !must("render debug page", ui.RenderDebugEnginePage(w, rooms.List())) {
+			return
+		}
+		if includeShell {
+			must("render shell end", ui.RenderShellEnd(w))
+		}
+	})
+
+	http.HandleFunc("/play", func(w http.ResponseWriter, r *http.Request) {
+		rm, ok := rooms.Match()
+		if !ok {
+			w.Header().Set("Location", "/")
+		} else {
+			w.Header().Set("Location", "/room/"+rm.ID)
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(302)
+	})
+
+	http.HandleFunc("/room", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			w.WriteHeader(405)
+			return
+		}
+		width, _ := strconv.ParseFloat(r.FormValue("w"), 64)
+		height, _ := strconv.ParseFloat(r.FormValue("h"), 64)
+		var teams []string
+		for _, team := range strings.Split(r.FormValue("teams"), ",") {
+			if team = strings.TrimSpace(team); team != "" {
+				teams = append(teams, team)
+			}
+		}
+		rm, err := rooms.Create(room.Settings{
+			Name:         r.FormValue("name"),
+			Width:        width,
+			Height:       height,
+			FriendlyFire: r.FormValue("friendlyFire") == "on",
+			Teams:        teams,
+		}, opts...)
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if err := rm.Engine.Start(ctx); err != nil {
+			log.Printf("unable to start room %s: %v", rm.ID, err)
+			rooms.Remove(rm.ID)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Location", "/room/"+rm.ID)
+		w.WriteHeader(303)
+	})
+
+	http.HandleFunc("/room/{room}", withRoom(rooms, func(w http.ResponseWriter, r *http.Request, rm *room.Room) {
+		if r.Method == "DELETE" || (r.Method == "POST" && r.FormValue("action") == "delete") {
+			rooms.Remove(rm.ID)
+			w.Header().Set("Location", "/")
+			w.WriteHeader(303)
+			return
+		}
+		if r.Method != "GET" {
+			w.WriteHeader(405)
+			return
+		}
+		game := rm.Engine
 		game.Lock()
-		p := game.SpawnPlayer()
-		w.Header().Set("Location", "/player/"+p)
+		if game.Replaying() {
+			w.Header().Set("Location", "/room/"+rm.ID+"/replay")
+		} else {
+			p := game.SpawnPlayer()
+			w.Header().Set("Location", "/room/"+rm.ID+"/player/"+p)
+		}
 		game.Unlock()
 		w.Header().Set("Cache-Control", "no-store")
 		w.WriteHeader(302)
+	}))
+
+	http.HandleFunc("/room/{room}/admin", withAdmin(*adminSecret, withRoom(rooms, func(w http.ResponseWriter, r *http.Request, rm *room.Room) {
+		if r.Method != "POST" {
+			w.WriteHeader(405)
+			return
+		}
+		game := rm.Engine
+		game.Lock()
+		defer game.Unlock()
+		switch r.FormValue("action") {
+		case "pause":
+			game.Pause()
+		case "resume":
+			game.Resume()
+		case "timeScale":
+			scale, err := strconv.ParseFloat(r.FormValue("scale"), 64)
+			if err == nil {
+				err = game.SetTimeScale(scale)
+			}
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte(err.Error()))
+				return
+			}
+		default:
+			w.WriteHeader(400)
+			return
+		}
+		w.Header().Set("Location", "/debug/engine")
+		w.WriteHeader(303)
+	})))
+
+	http.HandleFunc("/room/{room}/snapshot", withRoom(rooms, func(w http.ResponseWriter, r *http.Request, rm *room.Room) {
+		if r.Method != "GET" {
+			w.WriteHeader(405)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", `attachment; filename="snapshot.json"`)
+		must("write snapshot", rm.Engine.WriteSnapshot(w))
+	}))
+
+	http.HandleFunc("/room/{room}/recording", withRoom(rooms, func(w http.ResponseWriter, r *http.Request, rm *room.Room) {
+		if r.Method != "GET" {
+			w.WriteHeader(405)
+			return
+		}
+		rm.Engine.Lock()
+		replaying := rm.Engine.Replaying()
+		rm.Engine.Unlock()
+		if replaying {
+			w.WriteHeader(404)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", `attachment; filename="recording.json"`)
+		must("write recording", rm.Engine.WriteRecording(w))
+	}))
+
+	http.HandleFunc("/replay", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			w.WriteHeader(405)
+			return
+		}
+		f, _, err := r.FormFile("recording")
+		if err != nil {
+			w.WriteHeader(400)
+			return
+		}
+		defer f.Close()
+		recording, err := engine.ReadRecording(f)
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		rm, err := rooms.CreateReplay(r.FormValue("name"), recording)
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if err := rm.Engine.Start(ctx); err != nil {
+			log.Printf("unable to start replay %s: %v", rm.ID, err)
+			rooms.Remove(rm.ID)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Location", "/room/"+rm.ID+"/replay")
+		w.WriteHeader(303)
 	})
 
-	http.HandleFunc("/field", func(w http.ResponseWriter, r *http.Request) {
+	http.HandleFunc("/room/{room}/replay", withRoom(rooms, func(w http.ResponseWriter, r *http.Request, rm *room.Room) {
+		game := rm.Engine
+		game.Lock()
+		defer game.Unlock()
+		if !game.Replaying() {
+			w.WriteHeader(404)
+			return
+		}
+
+		if r.Method == "GET" {
+			w.Header().Set("Cache-Control", "no-store")
+			if !must("render shell start", ui.RenderShellStart(w)) {
+				return
+			}
+			if !must("render replay page", ui.RenderReplayPage(w, rm.ID, game)) {
+				return
+			}
+			must("render shell end", ui.RenderShellEnd(w))
+			return
+		}
+		if r.Method != "POST" {
+			w.WriteHeader(405)
+			return
+		}
+
+		switch r.FormValue("action") {
+		case "play":
+			if game.Tick() >= game.ReplayTicks() {
+				must("rewind replay", game.Seek(0))
+			}
+			game.Resume()
+		case "pause":
+			game.Pause()
+		case "seek":
+			t, err := strconv.ParseFloat(r.FormValue("t"), 64)
+			if err != nil || t < 0 {
+				w.WriteHeader(400)
+				return
+			}
+			if !must("seek replay", game.Seek(uint64(t*float64(game.TickRate())))) {
+				w.WriteHeader(500)
+				return
+			}
+		default:
+			w.WriteHeader(400)
+			return
+		}
+		w.Header().Set("Location", "/room/"+rm.ID+"/replay")
+		w.WriteHeader(303)
+	}))
+
+	http.HandleFunc("/room/{room}/field", withRoom(rooms, func(w http.ResponseWriter, r *http.Request, rm *room.Room) {
 		q := r.URL.Query()
 		width := q.Get("w")
 		height := q.Get("h")
@@ -137,16 +453,166 @@ func main() {
 			return
 		}
 
+		game := rm.Engine
 		game.Lock()
+		game.RecordPoll(q.Get("playerId"))
 		cstate.Update(game)
 		game.Unlock()
 
 		if !must("render field", ui.RenderField(w, cstate)) {
 			return
 		}
-	})
+	}))
 
-	log.Fatal(http.ListenAndServe("127.0.0.1:3000", nil))
+	server := &http.Server{Addr: "127.0.0.1:3000"}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("unable to serve: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Printf("shutting down")
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelShutdown()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("unable to shut down server: %v", err)
+	}
+	rooms.StopAll()
+
+	if *snapshotPath != "" {
+		if _, ok := rooms.Get(defaultRoom.ID); !ok {
+			return
+		}
+		if must("save snapshot", defaultRoom.Engine.SaveSnapshot(*snapshotPath)) {
+			log.Printf("saved snapshot %s", *snapshotPath)
+		}
+	}
+}
+
+// simulate runs a room without HTTP and prints statistics, see sim.Run.
+func simulate(args []string) {
+	flags := flag.NewFlagSet("simulate", flag.ExitOnError)
+	players := flags.Int("players", 4, "number of random-walk players")
+	duration := flags.Duration("duration", 10*time.Minute, "simulated game time")
+	respawnDelay := flags.Duration("respawn-delay", 3*time.Second, "time until dead players respawn")
+	seed := flags.Uint64("seed", rand.Uint64(), "seed of the world")
+	width := flags.Float64("w", 1000, "width of the world")
+	height := flags.Float64("h", 600, "height of the world")
+	prefabsPath := flags.String("prefabs", "", "load additional entity prefabs from the .json files in this directory")
+	configPath := flags.String("config", "", "load game tuning from this file")
+	flags.Parse(args)
+
+	opts := prefabOptions(*prefabsPath)
+	if *configPath != "" {
+		config, err := engine.LoadConfig(*configPath)
+		if err != nil {
+			log.Fatalf("unable to load config: %v", err)
+		}
+		opts = append(opts, engine.WithConfig(config))
+	}
+
+	stats, err := sim.Run(sim.Config{
+		Width:        *width,
+		Height:       *height,
+		Players:      *players,
+		Duration:     duration.Seconds(),
+		RespawnDelay: respawnDelay.Seconds(),
+		Seed:         *seed,
+		TickRate:     engine.DefaultTickRate,
+	}, opts...)
+	if err != nil {
+		log.Fatalf("unable to simulate: %v", err)
+	}
+	must("print stats", stats.Print(os.Stdout))
+}
+
+// watchConfig polls the config file and calls apply when it changed. Invalid configs are logged and ignored.
+func watchConfig(ctx context.Context, path string, apply func(config engine.Config) error) {
+	var modTime time.Time
+	if info, err := os.Stat(path); err == nil {
+		modTime = info.ModTime()
+	}
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		info, err := os.Stat(path)
+		if err != nil || info.ModTime().Equal(modTime) {
+			continue
+		}
+		modTime = info.ModTime()
+		config, err := engine.LoadConfig(path)
+		if err == nil {
+			err = apply(config)
+		}
+		if err != nil {
+			log.Printf("rejected config %s, keeping the previous one: %v", path, err)
+			continue
+		}
+		log.Printf("reloaded config %s", path)
+	}
+}
+
+func prefabOptions(dir string) []engine.Option {
+	if dir == "" {
+		return nil
+	}
+	prefabs, err := engine.LoadPrefabs(os.DirFS(dir))
+	if err != nil {
+		log.Fatalf("unable to load prefabs: %v", err)
+	}
+	return []engine.Option{engine.WithPrefabs(prefabs)}
+}
+
+func withRoom(rooms *room.Manager, handler func(w http.ResponseWriter, r *http.Request, rm *room.Room)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rm, ok := rooms.Get(r.PathValue("room"))
+		if !ok {
+			w.WriteHeader(404)
+			return
+		}
+		handler(w, r, rm)
+	}
+}
+
+// withAdmin lets requests from the same machine through. Other requests, including the ones a reverse
+// proxy forwards, need the secret as basic auth password. If there is none, they are forbidden.
+func withAdmin(secret string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if local(r) {
+			handler(w, r)
+			return
+		}
+		if secret == "" {
+			w.WriteHeader(403)
+			return
+		}
+		_, password, ok := r.BasicAuth()
+		if !ok || subtle.ConstantTimeCompare([]byte(password), []byte(secret)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="admin"`)
+			w.WriteHeader(401)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+func local(r *http.Request) bool {
+	if r.Header.Get("X-Forwarded-For") != "" || r.Header.Get("Forwarded") != "" {
+		return false
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
 }
 
 func must(what string, err error) bool {
@@ -158,10 +624,5 @@ func must(what string, err error) bool {
 }
 
 type PlayerInput struct {
-	Commands []PlayerCommand `json:"commands"`
-}
-
-type PlayerCommand struct {
-	M string  `json:"m"`
-	V float64 `json:"v"`
+	Commands []engine.Command `json:"commands"`
 }